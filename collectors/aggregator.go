@@ -1,61 +1,195 @@
 package collectors
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"log"
 	"os"
 	"path/filepath"
+	"sync"
 	"time"
 
+	"kpi.trustroots.org/metrics"
 	"kpi.trustroots.org/models"
 )
 
 // Aggregator combines data from all collectors
 type Aggregator struct {
-	mongoCollector *MongoCollector
-	nostrCollector *NostrCollector
+	collectors   []Collector
+	lookbackDays int
+	granularity  Granularity
+	location     *time.Location
+	parallelism  int
+
+	// previous is the last data collected for the current date. Sections of
+	// collectors that fail are kept from it instead of aborting the run, using
+	// the sections recorded in their status.
+	previous *models.KPIData
 }
 
-// NewAggregator creates a new aggregator
-func NewAggregator(mongoCollector *MongoCollector, nostrCollector *NostrCollector) *Aggregator {
+// NewAggregator creates a new aggregator collecting lookbackDays days of data
+// grouped by the given granularity, with days starting at midnight in location,
+// running up to parallelism collectors at once
+func NewAggregator(collectors []Collector, lookbackDays int, granularity Granularity, location *time.Location, parallelism int) *Aggregator {
+	if parallelism < 1 {
+		parallelism = 1
+	}
+	if location == nil {
+		location = time.UTC
+	}
 	return &Aggregator{
-		mongoCollector: mongoCollector,
-		nostrCollector: nostrCollector,
+		collectors:   collectors,
+		lookbackDays: lookbackDays,
+		granularity:  granularity,
+		location:     location,
+		parallelism:  parallelism,
 	}
 }
 
-// CollectAllData collects all KPI data
-func (a *Aggregator) CollectAllData(targetDate *time.Time) (*models.KPIData, error) {
-	// Collect Trustroots data
-	trustrootsData, err := a.mongoCollector.CollectTrustrootsData(targetDate)
+// Location returns the reporting timezone
+func (a *Aggregator) Location() *time.Location {
+	return a.location
+}
+
+// Collectors returns the collectors run by the aggregator
+func (a *Aggregator) Collectors() []Collector {
+	return a.collectors
+}
+
+// LoadPrevious seeds the previous good values from an earlier output file,
+// so failing collectors can keep their values across restarts
+func (a *Aggregator) LoadPrevious(path string) error {
+	content, err := os.ReadFile(path)
 	if err != nil {
-		return nil, fmt.Errorf("failed to collect Trustroots data: %w", err)
+		return err
 	}
 
-	// Collect Nostroots data
-	nostrootsData, err := a.nostrCollector.CollectNostrootsData(targetDate)
-	if err != nil {
-		return nil, fmt.Errorf("failed to collect Nostroots data: %w", err)
+	var data models.KPIData
+	if err := json.Unmarshal(content, &data); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", path, err)
 	}
 
-	// Use target date or current time
+	a.previous = &data
+	return nil
+}
+
+// CollectAllData collects all KPI data. Collectors run concurrently and
+// independently: when one fails, its error is recorded in the status section
+// and its previous values are kept. An error is only returned if no data is
+// available or ctx was cancelled.
+func (a *Aggregator) CollectAllData(ctx context.Context, targetDate *time.Time) (*models.KPIData, error) {
+	window := NewWindow(targetDate, a.lookbackDays, a.granularity, a.location)
+
+	// Use target date or current time, in the reporting timezone so "yesterday"
+	// is derived from Generated consistently
 	var generatedTime time.Time
 	if targetDate != nil {
-		generatedTime = targetDate.UTC()
+		generatedTime = targetDate.In(a.location)
 	} else {
-		generatedTime = time.Now().UTC()
+		generatedTime = time.Now().In(a.location)
 	}
 
-	// Combine all data
+	// Previous good values are only kept when collecting current data
 	kpiData := &models.KPIData{
-		Generated:  generatedTime,
-		Trustroots: *trustrootsData,
-		Nostroots:  *nostrootsData,
+		Generated: generatedTime,
+		Status:    make(map[string]models.CollectorStatus),
+	}
+	var previous *models.KPIData
+	if targetDate == nil {
+		previous = a.previous
+	}
+
+	results := a.runCollectors(ctx, window)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	// Let every collector contribute its section, in registration order
+	failed, stale := 0, 0
+	for i, collector := range a.collectors {
+		name := collector.Name()
+		apply, err := results[i].apply, results[i].err
+
+		if err != nil {
+			failed++
+			log.Printf("Failed to collect %s data: %v", name, err)
+
+			// Keep the sections the collector wrote in the previous run, if any.
+			// Sections of collectors that are no longer enabled are dropped.
+			status := models.CollectorStatus{Error: err.Error()}
+			if previous != nil {
+				if last := previous.Status[name]; len(last.Sections) > 0 {
+					kpiData.CopySections(previous, last.Sections)
+					status.Stale = true
+					status.Sections = last.Sections
+					status.LastSuccess = last.LastSuccess
+					stale++
+				}
+			}
+			kpiData.Status[name] = status
+			continue
+		}
+
+		// Apply to an empty data set first to record which sections the
+		// collector owns
+		collected := &models.KPIData{}
+		apply(collected)
+		sections := collected.Sections()
+		kpiData.CopySections(collected, sections)
+
+		collectedAt := time.Now().UTC()
+		kpiData.Status[name] = models.CollectorStatus{OK: true, LastSuccess: &collectedAt, Sections: sections}
+	}
+
+	if failed > 0 && failed == len(a.collectors) && stale == 0 {
+		return nil, fmt.Errorf("all %d collectors failed", failed)
+	}
+
+	if targetDate == nil {
+		a.previous = kpiData
 	}
 
 	return kpiData, nil
 }
 
+// collectorResult is the outcome of a single collector run
+type collectorResult struct {
+	apply func(*models.KPIData)
+	err   error
+}
+
+// runCollectors runs all collectors with bounded parallelism and returns
+// their results in the same order as a.collectors
+func (a *Aggregator) runCollectors(ctx context.Context, window Window) []collectorResult {
+	results := make([]collectorResult, len(a.collectors))
+	semaphore := make(chan struct{}, a.parallelism)
+
+	var wg sync.WaitGroup
+	for i, collector := range a.collectors {
+		wg.Add(1)
+		go func(i int, collector Collector) {
+			defer wg.Done()
+
+			select {
+			case semaphore <- struct{}{}:
+				defer func() { <-semaphore }()
+			case <-ctx.Done():
+				results[i].err = ctx.Err()
+				return
+			}
+
+			start := time.Now()
+			apply, err := collector.Collect(ctx, window)
+			metrics.ObserveCollector(collector.Name(), time.Since(start), err)
+			results[i] = collectorResult{apply: apply, err: err}
+		}(i, collector)
+	}
+	wg.Wait()
+
+	return results
+}
+
 // SaveToFile saves KPI data to JSON file
 func (a *Aggregator) SaveToFile(data *models.KPIData, outputPath string) error {
 	// Create directory if it doesn't exist
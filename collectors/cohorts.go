@@ -0,0 +1,182 @@
+package collectors
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"kpi.trustroots.org/metrics"
+	"kpi.trustroots.org/models"
+)
+
+// CohortOptions configures the cohort retention matrix
+type CohortOptions struct {
+	Granularity Granularity // Week or month cohorts
+	Periods     int         // Number of cohorts, ending with the current period
+}
+
+// cohortMembers assigns users to the cohort of the period they signed up in
+var cohortMembers = activitySource{collection: "users", field: "created", user: "$_id"}
+
+// cohortSources are the actions counted as retained activity
+var cohortSources = []activitySource{
+	{collection: "messages", field: "created", user: "$userFrom"},
+	{collection: "experiences", field: "created", user: "$userFrom"},
+}
+
+func init() {
+	Register("cohorts", func(deps Dependencies) (Collector, error) {
+		if deps.Mongo == nil {
+			return nil, fmt.Errorf("MongoDB is not configured")
+		}
+
+		options := deps.Cohorts
+		if options.Granularity == "" {
+			options.Granularity = GranularityMonth
+		}
+		if options.Granularity == GranularityDay {
+			return nil, fmt.Errorf("cohorts need week or month granularity")
+		}
+		if options.Periods <= 0 {
+			options.Periods = 6
+		}
+
+		return collectorFunc{
+			name: "cohorts",
+			collect: func(ctx context.Context, window Window) (func(*models.KPIData), error) {
+				cohorts, err := deps.Mongo.collectCohorts(ctx, cohortWindow(window, options))
+				if err != nil {
+					return nil, err
+				}
+				return func(data *models.KPIData) { data.Trustroots.Cohorts = cohorts }, nil
+			},
+		}, nil
+	})
+	markSnapshot("cohorts")
+	markFixedGranularity("cohorts")
+}
+
+// cohortWindow returns the window covering the configured number of cohort
+// periods up to the end of the collection window
+func cohortWindow(window Window, options CohortOptions) Window {
+	w := Window{
+		Until:       window.Until,
+		Granularity: options.Granularity,
+		Location:    window.Location,
+	}
+
+	last := w.periodStart(w.dayStart(window.Until, -1))
+	switch options.Granularity {
+	case GranularityWeek:
+		w.Since = w.dayStart(last, -7*(options.Periods-1))
+	default:
+		w.Since = time.Date(last.Year(), last.Month()-time.Month(options.Periods-1), 1, 0, 0, 0, 0, w.location())
+	}
+
+	return w
+}
+
+// collectCohorts builds the retention matrix of the users who signed up in
+// each period of the window
+func (mc *MongoCollector) collectCohorts(ctx context.Context, window Window) (*models.CohortData, error) {
+	members := make(map[string]map[string]bool)
+	if err := mc.collectCohortQuery(ctx, window, cohortMembers, members); err != nil {
+		return nil, fmt.Errorf("failed to collect cohort members: %w", err)
+	}
+
+	active := make(map[string]map[string]bool)
+	for _, source := range cohortSources {
+		if err := mc.collectCohortQuery(ctx, window, source, active); err != nil {
+			return nil, fmt.Errorf("failed to collect %s activity: %w", source.collection, err)
+		}
+	}
+
+	periods := window.Periods()
+	data := &models.CohortData{
+		Granularity: string(window.Granularity),
+		Cohorts:     make([]models.CohortRow, 0, len(periods)),
+	}
+	for i, cohort := range periods {
+		row := models.CohortRow{
+			Cohort: cohort,
+			Size:   len(members[cohort]),
+		}
+		for _, period := range periods[i:] {
+			retained := 0
+			for user := range members[cohort] {
+				if active[period][user] {
+					retained++
+				}
+			}
+
+			retention := 0.0
+			if row.Size > 0 {
+				retention = float64(retained) / float64(row.Size)
+			}
+			row.Retained = append(row.Retained, retained)
+			row.Retention = append(row.Retention, retention)
+		}
+		data.Cohorts = append(data.Cohorts, row)
+	}
+
+	return data, nil
+}
+
+// collectCohortQuery runs a single cohort query with its own deadline
+func (mc *MongoCollector) collectCohortQuery(ctx context.Context, window Window, source activitySource, usersByPeriod map[string]map[string]bool) error {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	start := time.Now()
+	err := mc.collectActivity(ctx, window, source, usersByPeriod)
+	metrics.ObserveMongoQuery("cohorts", time.Since(start), err)
+	return err
+}
+
+// WriteCohortCSV writes the retention matrix as CSV with one row per cohort
+// and the retention share of each following period as columns
+func WriteCohortCSV(cohorts *models.CohortData, outputPath string) error {
+	if err := os.MkdirAll(filepath.Dir(outputPath), 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	periods := 0
+	for _, row := range cohorts.Cohorts {
+		if len(row.Retention) > periods {
+			periods = len(row.Retention)
+		}
+	}
+
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+
+	header := []string{"cohort", "size"}
+	for i := 0; i < periods; i++ {
+		header = append(header, fmt.Sprintf("%s_%d", cohorts.Granularity, i))
+	}
+	writer.Write(header)
+
+	for _, row := range cohorts.Cohorts {
+		record := []string{row.Cohort, strconv.Itoa(row.Size)}
+		for _, retention := range row.Retention {
+			record = append(record, strconv.FormatFloat(retention, 'f', 4, 64))
+		}
+		writer.Write(record)
+	}
+
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("failed to encode CSV: %w", err)
+	}
+
+	if err := os.WriteFile(outputPath, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,398 @@
+package collectors
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+
+	"kpi.trustroots.org/metrics"
+	"kpi.trustroots.org/models"
+)
+
+// contactTrackingDays is how long unconfirmed requests are watched for a
+// confirmation
+const contactTrackingDays = 30
+
+// contactBuckets labels the lower bounds of the contacts per user ranges
+var contactBuckets = []struct {
+	lower int
+	label string
+}{
+	{0, "0"},
+	{1, "1"},
+	{2, "2-5"},
+	{6, "6-10"},
+	{11, "11-25"},
+	{26, "26+"},
+}
+
+// ContactOptions configures the contacts collector
+type ContactOptions struct {
+	StatePath      string        // File remembering unconfirmed requests between runs, empty to disable
+	UpdateInterval time.Duration // Time between runs, bounding how late a confirmation is seen
+}
+
+// contactRequest is a contact request as stored in the contacts collection
+type contactRequest struct {
+	ID        string    `bson:"_id"`
+	Created   time.Time `bson:"created"`
+	Confirmed bool      `bson:"confirmed"`
+}
+
+// contactState is what the contacts collector remembers between runs to see
+// when requests get confirmed
+type contactState struct {
+	LastRun   time.Time            `json:"lastRun"`
+	Pending   map[string]bool      `json:"pending"`   // Unconfirmed requests by ID
+	Confirmed map[string]time.Time `json:"confirmed"` // Time a request was first seen confirmed, by ID
+}
+
+// ContactsCollector collects trust network metrics from contacts
+type ContactsCollector struct {
+	mongo   *MongoCollector
+	options ContactOptions
+
+	mu    sync.Mutex
+	state *contactState
+}
+
+// NewContactsCollector creates a new contacts collector
+func NewContactsCollector(mongo *MongoCollector, options ContactOptions) *ContactsCollector {
+	return &ContactsCollector{
+		mongo:   mongo,
+		options: options,
+	}
+}
+
+func init() {
+	Register("contacts", func(deps Dependencies) (Collector, error) {
+		if deps.Mongo == nil {
+			return nil, fmt.Errorf("MongoDB is not configured")
+		}
+		return NewContactsCollector(deps.Mongo, deps.Contacts), nil
+	})
+}
+
+// Name returns the collector name
+func (cc *ContactsCollector) Name() string {
+	return "contacts"
+}
+
+// Collect gathers the contact metrics for the window
+func (cc *ContactsCollector) Collect(ctx context.Context, window Window) (func(*models.KPIData), error) {
+	// Requests are also fetched for the tracking period, so confirmations of
+	// requests sent before the window are still seen
+	since := window.dayStart(window.Until, -contactTrackingDays)
+	if window.Since.Before(since) {
+		since = window.Since
+	}
+
+	var requests []contactRequest
+	err := cc.observe(ctx, func(ctx context.Context) error {
+		var err error
+		requests, err = cc.findRequests(ctx, since, window.Until)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to find contact requests: %w", err)
+	}
+
+	// Contacts are not stored with a time, so the buckets are a snapshot of
+	// the current state and left out for past windows
+	var buckets []models.ContactBucket
+	if !window.Past() {
+		err = cc.observe(ctx, func(ctx context.Context) error {
+			var err error
+			buckets, err = cc.collectContactsPerActiveUser(ctx, window.Until)
+			return err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to count contacts per user: %w", err)
+		}
+	}
+
+	confirmedAt := cc.trackConfirmations(requests, window)
+	perDay := contactsPerDay(window, requests, confirmedAt)
+
+	return func(data *models.KPIData) {
+		data.Trustroots.ContactsPerDay = perDay
+		data.Trustroots.ContactsPerActiveUser = buckets
+	}, nil
+}
+
+// observe runs a single Mongo query with its own deadline and records its latency
+func (cc *ContactsCollector) observe(ctx context.Context, query func(context.Context) error) error {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	start := time.Now()
+	err := query(ctx)
+	metrics.ObserveMongoQuery(cc.Name(), time.Since(start), err)
+	return err
+}
+
+// findRequests returns the contact requests sent in [since, until)
+func (cc *ContactsCollector) findRequests(ctx context.Context, since, until time.Time) ([]contactRequest, error) {
+	pipeline := []bson.M{
+		{
+			"$match": bson.M{
+				"created": bson.M{
+					"$gte": since,
+					"$lt":  until,
+				},
+			},
+		},
+		{
+			"$project": bson.M{
+				"_id":       bson.M{"$toString": "$_id"},
+				"created":   1,
+				"confirmed": bson.M{"$eq": []interface{}{"$confirmed", true}},
+			},
+		},
+	}
+
+	cursor, err := cc.mongo.GetDatabase().Collection("contacts").Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var requests []contactRequest
+	for cursor.Next(ctx) {
+		var request contactRequest
+		if err := cursor.Decode(&request); err != nil {
+			log.Printf("Error decoding contact request: %v", err)
+			continue
+		}
+		requests = append(requests, request)
+	}
+
+	return requests, cursor.Err()
+}
+
+// trackConfirmations records requests that were unconfirmed in an earlier run,
+// or sent since then, and are confirmed now. It returns when each request was
+// first seen confirmed. Past windows, such as backfills, and rollups only read
+// the state, which the daily run keeps up to date.
+func (cc *ContactsCollector) trackConfirmations(requests []contactRequest, window Window) map[string]time.Time {
+	if cc.options.StatePath == "" {
+		return nil
+	}
+
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+
+	if cc.state == nil {
+		cc.state = loadContactState(cc.options.StatePath)
+	}
+
+	if window.Past() || window.Granularity != GranularityDay {
+		return cc.state.Confirmed
+	}
+	now := time.Now()
+
+	// Requests confirmed while the service was down would be timed up to this
+	// run, so confirmations seen after a long gap are not timed
+	gap := !cc.state.LastRun.IsZero() && cc.options.UpdateInterval > 0 && now.Sub(cc.state.LastRun) > 2*cc.options.UpdateInterval
+	if gap {
+		log.Printf("Last contacts run was %v ago, not timing confirmations seen since then", now.Sub(cc.state.LastRun).Round(time.Minute))
+	}
+
+	pending := make(map[string]bool)
+	confirmed := make(map[string]time.Time)
+	for _, request := range requests {
+		if !request.Confirmed {
+			pending[request.ID] = true
+			continue
+		}
+
+		if seenAt, exists := cc.state.Confirmed[request.ID]; exists {
+			confirmed[request.ID] = seenAt
+		} else if !gap && (cc.state.Pending[request.ID] || (!cc.state.LastRun.IsZero() && request.Created.After(cc.state.LastRun))) {
+			confirmed[request.ID] = now
+		}
+	}
+
+	// Requests older than the tracking period are dropped from the state
+	cc.state = &contactState{
+		LastRun:   now,
+		Pending:   pending,
+		Confirmed: confirmed,
+	}
+	if err := saveContactState(cc.options.StatePath, cc.state); err != nil {
+		log.Printf("Failed to save contact state: %v", err)
+	}
+
+	return confirmed
+}
+
+// contactsPerDay counts the requests sent in each period of the window, how
+// many are confirmed and the median time until they were seen confirmed
+func contactsPerDay(window Window, requests []contactRequest, confirmedAt map[string]time.Time) []models.DailyContacts {
+	byDate := make(map[string]models.DailyContacts)
+	confirmTimes := make(map[string][]int64)
+	for _, request := range requests {
+		if request.Created.Before(window.Since) {
+			continue
+		}
+
+		date := window.Key(request.Created)
+		contacts := byDate[date]
+		contacts.Date = date
+		contacts.Requests++
+		if request.Confirmed {
+			contacts.Confirmed++
+			if seenAt, exists := confirmedAt[request.ID]; exists {
+				confirmTimes[date] = append(confirmTimes[date], seenAt.Sub(request.Created).Milliseconds())
+			}
+		}
+		byDate[date] = contacts
+	}
+
+	for date, contacts := range byDate {
+		contacts.ConfirmationRate = ratio(contacts.Confirmed, contacts.Requests)
+		contacts.MedianTimeToConfirmMs = percentile(confirmTimes[date], 50)
+		byDate[date] = contacts
+	}
+
+	// Periods without requests have zero counts and null rates
+	return buildSeries(window, byDate, func(date string) models.DailyContacts {
+		return models.DailyContacts{Date: date}
+	})
+}
+
+// collectContactsPerActiveUser buckets the users seen in the 30 days before
+// until by their number of confirmed contacts
+func (cc *ContactsCollector) collectContactsPerActiveUser(ctx context.Context, until time.Time) ([]models.ContactBucket, error) {
+	// Users with at least as many contacts as the last lower bound fall into
+	// the default bucket
+	boundaries := make([]interface{}, 0, len(contactBuckets))
+	for _, bucket := range contactBuckets {
+		boundaries = append(boundaries, bucket.lower)
+	}
+	last := contactBuckets[len(contactBuckets)-1]
+
+	pipeline := []bson.M{
+		{
+			"$match": bson.M{
+				"seen": bson.M{
+					"$gte": until.AddDate(0, 0, -30),
+					"$lt":  until,
+				},
+			},
+		},
+		// Contacts are looked up once per side, so each lookup can use the
+		// index on its user field
+		lookupConfirmedContacts("userFrom", "sent"),
+		lookupConfirmedContacts("userTo", "received"),
+		{
+			"$bucket": bson.M{
+				"groupBy": bson.M{
+					"$add": []interface{}{
+						bson.M{"$ifNull": []interface{}{bson.M{"$arrayElemAt": []interface{}{"$sent.count", 0}}, 0}},
+						bson.M{"$ifNull": []interface{}{bson.M{"$arrayElemAt": []interface{}{"$received.count", 0}}, 0}},
+					},
+				},
+				"boundaries": boundaries,
+				"default":    last.label,
+				"output":     bson.M{"users": bson.M{"$sum": 1}},
+			},
+		},
+	}
+
+	cursor, err := cc.mongo.GetDatabase().Collection("users").Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	users := make(map[string]int)
+	for cursor.Next(ctx) {
+		var result struct {
+			ID    interface{} `bson:"_id"`
+			Users int         `bson:"users"`
+		}
+		if err := cursor.Decode(&result); err != nil {
+			log.Printf("Error decoding contacts per user result: %v", err)
+			continue
+		}
+		users[fmt.Sprint(result.ID)] += result.Users
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, err
+	}
+
+	// Buckets are keyed by their lower bound, except the open-ended last one
+	buckets := make([]models.ContactBucket, 0, len(contactBuckets))
+	for i, bucket := range contactBuckets {
+		key := fmt.Sprint(bucket.lower)
+		if i == len(contactBuckets)-1 {
+			key = bucket.label
+		}
+		buckets = append(buckets, models.ContactBucket{Contacts: bucket.label, Users: users[key]})
+	}
+
+	return buckets, nil
+}
+
+// lookupConfirmedContacts counts the confirmed contacts whose field is the user
+// into a single {count} document in as. Combining localField with a pipeline
+// needs MongoDB 5.0.
+func lookupConfirmedContacts(field, as string) bson.M {
+	return bson.M{
+		"$lookup": bson.M{
+			"from":         "contacts",
+			"localField":   "_id",
+			"foreignField": field,
+			"pipeline": []bson.M{
+				{"$match": bson.M{"confirmed": true}},
+				{"$count": "count"},
+			},
+			"as": as,
+		},
+	}
+}
+
+// loadContactState reads the contact state, starting over if it is missing or
+// unreadable
+func loadContactState(path string) *contactState {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("Failed to read contact state, starting over: %v", err)
+		}
+		return &contactState{}
+	}
+
+	var state contactState
+	if err := json.Unmarshal(content, &state); err != nil {
+		log.Printf("Failed to parse contact state, starting over: %v", err)
+		return &contactState{}
+	}
+	return &state
+}
+
+// saveContactState writes the contact state atomically
+func saveContactState(path string, state *contactState) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+
+	content, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("failed to marshal state: %w", err)
+	}
+
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, content, 0644); err != nil {
+		return fmt.Errorf("failed to write state: %w", err)
+	}
+	return os.Rename(tmpPath, path)
+}
@@ -0,0 +1,299 @@
+package collectors
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+
+	"kpi.trustroots.org/metrics"
+	"kpi.trustroots.org/models"
+)
+
+// GeoOptions configures the geographic breakdown
+type GeoOptions struct {
+	TopN int // Number of countries or cities in each top table
+}
+
+// geoSource is an activity counted per country of the user behind it
+type geoSource struct {
+	metric     string
+	collection string
+	field      string // Date of the activity
+	user       string // Field referencing the user, empty if the documents are users
+}
+
+// geoSources are the activities broken down by country
+var geoSources = []geoSource{
+	{metric: "messages", collection: "messages", field: "created", user: "userFrom"},
+	{metric: "reviews", collection: "experiences", field: "created", user: "userFrom"},
+	{metric: "signups", collection: "users", field: "created"},
+	{metric: "offers", collection: "offers", field: "created", user: "user"},
+}
+
+// GeoCollector breaks activity down by the country and city users live in
+type GeoCollector struct {
+	mongo   *MongoCollector
+	options GeoOptions
+}
+
+// NewGeoCollector creates a new geographic breakdown collector
+func NewGeoCollector(mongo *MongoCollector, options GeoOptions) *GeoCollector {
+	if options.TopN <= 0 {
+		options.TopN = 10
+	}
+	return &GeoCollector{
+		mongo:   mongo,
+		options: options,
+	}
+}
+
+func init() {
+	// Looking up the country of every active user is expensive, so the
+	// breakdown only runs when enabled
+	RegisterOptional("geo", func(deps Dependencies) (Collector, error) {
+		if deps.Mongo == nil {
+			return nil, fmt.Errorf("MongoDB is not configured")
+		}
+		return NewGeoCollector(deps.Mongo, deps.Geo), nil
+	})
+	markSnapshot("geo")
+}
+
+// Name returns the collector name
+func (gc *GeoCollector) Name() string {
+	return "geo"
+}
+
+// Collect gathers the activity per country and city for the window
+func (gc *GeoCollector) Collect(ctx context.Context, window Window) (func(*models.KPIData), error) {
+	// Counts by metric, location and period
+	counts := make(map[string]map[string]map[string]int)
+	for _, source := range geoSources {
+		queryCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+		start := time.Now()
+		byLocation, err := gc.countByLocation(queryCtx, window, source)
+		metrics.ObserveMongoQuery(gc.Name(), time.Since(start), err)
+		cancel()
+		if err != nil {
+			return nil, fmt.Errorf("failed to collect %s by location: %w", source.metric, err)
+		}
+		counts[source.metric] = byLocation
+	}
+
+	geo := gc.buildGeoData(window, counts)
+	return func(data *models.KPIData) { data.Trustroots.Geo = geo }, nil
+}
+
+// countByLocation counts the activity of a source by the location its user
+// lives in and by period
+func (gc *GeoCollector) countByLocation(ctx context.Context, window Window, source geoSource) (map[string]map[string]int, error) {
+	pipeline := []bson.M{
+		{
+			"$match": bson.M{
+				source.field: window.MatchRange(),
+			},
+		},
+	}
+
+	if source.user != "" {
+		// Count per user first, so each user's location is only looked up once
+		pipeline = append(pipeline,
+			bson.M{
+				"$group": bson.M{
+					"_id": bson.M{
+						"user": "$" + source.user,
+						"date": window.DateToString("$" + source.field),
+					},
+					"count": bson.M{"$sum": 1},
+				},
+			},
+			bson.M{
+				"$lookup": bson.M{
+					"from": "users",
+					"let":  bson.M{"user": "$_id.user"},
+					"pipeline": []bson.M{
+						{"$match": bson.M{"$expr": bson.M{"$eq": []interface{}{"$_id", "$$user"}}}},
+						{"$project": bson.M{"locationLiving": 1}},
+					},
+					"as": "user",
+				},
+			},
+			bson.M{
+				"$project": bson.M{
+					"date":           "$_id.date",
+					"count":          1,
+					"locationLiving": bson.M{"$arrayElemAt": []interface{}{"$user.locationLiving", 0}},
+				},
+			},
+		)
+	} else {
+		pipeline = append(pipeline, bson.M{
+			"$project": bson.M{
+				"date":           window.DateToString("$" + source.field),
+				"count":          bson.M{"$literal": 1},
+				"locationLiving": 1,
+			},
+		})
+	}
+	pipeline = append(pipeline, bson.M{
+		"$group": bson.M{
+			"_id": bson.M{
+				"date":     "$date",
+				"location": "$locationLiving",
+			},
+			"count": bson.M{"$sum": "$count"},
+		},
+	})
+
+	cursor, err := gc.mongo.GetDatabase().Collection(source.collection).Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	byLocation := make(map[string]map[string]int)
+	for cursor.Next(ctx) {
+		var result struct {
+			ID struct {
+				Date     string `bson:"date"`
+				Location string `bson:"location"`
+			} `bson:"_id"`
+			Count int `bson:"count"`
+		}
+		if err := cursor.Decode(&result); err != nil {
+			log.Printf("Error decoding %s location result: %v", source.metric, err)
+			continue
+		}
+
+		location := strings.TrimSpace(result.ID.Location)
+		if byLocation[location] == nil {
+			byLocation[location] = make(map[string]int)
+		}
+		byLocation[location][result.ID.Date] += result.Count
+	}
+
+	return byLocation, cursor.Err()
+}
+
+// geoBreakdown is the activity grouped by country or by city
+type geoBreakdown struct {
+	top    map[string][]models.CountryCount    // Places with the highest totals by metric
+	places []string                            // Places in any top table
+	perDay map[string][]models.DailyGeoMetrics // Series by place
+}
+
+// buildGeoData turns the counts by metric, location and period into top tables
+// and series by country and by city
+func (gc *GeoCollector) buildGeoData(window Window, counts map[string]map[string]map[string]int) *models.GeoData {
+	countries := gc.breakdown(window, counts, countryFromLocation)
+	cities := gc.breakdown(window, counts, cityFromLocation)
+
+	geo := &models.GeoData{
+		TopMessages: countries.top["messages"],
+		TopReviews:  countries.top["reviews"],
+		TopSignups:  countries.top["signups"],
+		TopOffers:   countries.top["offers"],
+		Countries:   make([]models.CountrySeries, 0, len(countries.places)),
+		Cities: models.CityData{
+			TopMessages: cityCounts(cities.top["messages"]),
+			TopReviews:  cityCounts(cities.top["reviews"]),
+			TopSignups:  cityCounts(cities.top["signups"]),
+			TopOffers:   cityCounts(cities.top["offers"]),
+			Series:      make([]models.CitySeries, 0, len(cities.places)),
+		},
+	}
+	for _, country := range countries.places {
+		geo.Countries = append(geo.Countries, models.CountrySeries{Country: country, PerDay: countries.perDay[country]})
+	}
+	for _, city := range cities.places {
+		geo.Cities.Series = append(geo.Cities.Series, models.CitySeries{City: city, PerDay: cities.perDay[city]})
+	}
+
+	return geo
+}
+
+// breakdown groups the counts by the place locate returns for each location,
+// and builds the top tables and a series for every place in any of them
+func (gc *GeoCollector) breakdown(window Window, counts map[string]map[string]map[string]int, locate func(string) string) geoBreakdown {
+	// Counts by metric, place and period
+	byPlace := make(map[string]map[string]map[string]int)
+	for metric, byLocation := range counts {
+		places := make(map[string]map[string]int)
+		for location, byDate := range byLocation {
+			place := locate(location)
+			if places[place] == nil {
+				places[place] = make(map[string]int)
+			}
+			for date, count := range byDate {
+				places[place][date] += count
+			}
+		}
+		byPlace[metric] = places
+	}
+
+	result := geoBreakdown{
+		top:    make(map[string][]models.CountryCount),
+		perDay: make(map[string][]models.DailyGeoMetrics),
+	}
+	for _, source := range geoSources {
+		totals := make(map[string]int)
+		for place, byDate := range byPlace[source.metric] {
+			for _, count := range byDate {
+				totals[place] += count
+			}
+		}
+
+		table := sortedCountryCounts(totals)
+		if len(table) > gc.options.TopN {
+			table = table[:gc.options.TopN]
+		}
+		result.top[source.metric] = table
+
+		for _, entry := range table {
+			if _, included := result.perDay[entry.Country]; included {
+				continue
+			}
+			result.places = append(result.places, entry.Country)
+
+			var series []models.DailyGeoMetrics
+			for _, date := range window.Periods() {
+				series = append(series, models.DailyGeoMetrics{
+					Date:     date,
+					Messages: byPlace["messages"][entry.Country][date],
+					Reviews:  byPlace["reviews"][entry.Country][date],
+					Signups:  byPlace["signups"][entry.Country][date],
+					Offers:   byPlace["offers"][entry.Country][date],
+				})
+			}
+			result.perDay[entry.Country] = series
+		}
+	}
+
+	return result
+}
+
+// cityFromLocation extracts the city from a free-form location such as
+// "Berlin, Germany" as its first part. The country is kept, so cities with
+// the same name in different countries are told apart.
+func cityFromLocation(location string) string {
+	parts := strings.Split(location, ",")
+	city := strings.TrimSpace(parts[0])
+	if len(parts) < 2 || city == "" {
+		return "Unknown"
+	}
+	return city + ", " + countryFromLocation(location)
+}
+
+// cityCounts converts a top table of cities, built like the country tables, to
+// city counts
+func cityCounts(table []models.CountryCount) []models.CityCount {
+	result := make([]models.CityCount, 0, len(table))
+	for _, entry := range table {
+		result = append(result, models.CityCount{City: entry.Country, Count: entry.Count})
+	}
+	return result
+}
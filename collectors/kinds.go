@@ -0,0 +1,111 @@
+package collectors
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+
+	"kpi.trustroots.org/models"
+)
+
+// Nostr kind categories
+const (
+	KindCategorySocial    = "social"
+	KindCategoryCommunity = "community"
+	KindCategoryDM        = "dm"
+	KindCategoryMetadata  = "metadata"
+)
+
+// DefaultNostrKinds returns the kind catalogue used when no kinds file is configured
+func DefaultNostrKinds() []models.NostrKind {
+	return []models.NostrKind{
+		{Kind: 0, Label: "Profile Metadata", Category: KindCategoryMetadata, Query: true, Report: true},
+		{Kind: 1, Label: "Notes", Category: KindCategorySocial, Query: true, Report: true},
+		{Kind: 4, Label: "Encrypted DMs", Category: KindCategoryDM, Query: true, Report: true},
+		{Kind: 30023, Label: "Long-form Content", Category: KindCategorySocial, Query: true, Report: true},
+		{Kind: 397, Label: "App-specific Data", Category: KindCategoryMetadata, Query: true, Report: true},
+		{Kind: 30398, Label: "Community Post", Category: KindCategoryCommunity, Query: true, Report: true},
+		{Kind: 30399, Label: "Community Post Reply", Category: KindCategoryCommunity, Query: true, Report: true},
+	}
+}
+
+// LoadNostrKinds reads a kind catalogue from a JSON file containing an array of
+// kinds. An empty path returns the default catalogue.
+func LoadNostrKinds(path string) ([]models.NostrKind, error) {
+	if path == "" {
+		return DefaultNostrKinds(), nil
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read kinds file: %w", err)
+	}
+
+	var kinds []models.NostrKind
+	if err := json.Unmarshal(content, &kinds); err != nil {
+		return nil, fmt.Errorf("failed to parse kinds file: %w", err)
+	}
+
+	if err := validateNostrKinds(kinds); err != nil {
+		return nil, fmt.Errorf("invalid kinds file %s: %w", path, err)
+	}
+
+	return kinds, nil
+}
+
+// validateNostrKinds checks that the catalogue has no duplicate kinds, known
+// categories, only reports kinds it queries and has at least one kind to query
+func validateNostrKinds(kinds []models.NostrKind) error {
+	seen := make(map[int]bool)
+	queried := 0
+	for _, kind := range kinds {
+		if kind.Kind < 0 {
+			return fmt.Errorf("invalid kind %d", kind.Kind)
+		}
+		if seen[kind.Kind] {
+			return fmt.Errorf("kind %d listed twice", kind.Kind)
+		}
+		seen[kind.Kind] = true
+
+		switch kind.Category {
+		case KindCategorySocial, KindCategoryCommunity, KindCategoryDM, KindCategoryMetadata:
+		default:
+			return fmt.Errorf("kind %d has unknown category '%s' (use social, community, dm or metadata)", kind.Kind, kind.Category)
+		}
+
+		if kind.Report && !kind.Query {
+			return fmt.Errorf("kind %d is reported but not queried (set query to true or report to false)", kind.Kind)
+		}
+		if kind.Query {
+			queried++
+		}
+	}
+
+	if queried == 0 {
+		return fmt.Errorf("no kinds to query")
+	}
+	return nil
+}
+
+// queryKinds returns the kinds to fetch from relays
+func queryKinds(kinds []models.NostrKind) []int {
+	var result []int
+	for _, kind := range kinds {
+		if kind.Query {
+			result = append(result, kind.Kind)
+		}
+	}
+	return result
+}
+
+// reportKinds returns the kinds counted per period, keyed as in DailyNotes
+func reportKinds(kinds []models.NostrKind) []string {
+	var result []string
+	for _, kind := range kinds {
+		if kind.Report {
+			result = append(result, strconv.Itoa(kind.Kind))
+		}
+	}
+	return result
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"sort"
+	"strings"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
@@ -12,6 +14,7 @@ import (
 	"go.mongodb.org/mongo-driver/mongo/readconcern"
 	"go.mongodb.org/mongo-driver/mongo/readpref"
 
+	"kpi.trustroots.org/metrics"
 	"kpi.trustroots.org/models"
 )
 
@@ -59,47 +62,69 @@ func (mc *MongoCollector) GetDatabase() *mongo.Database {
 	return mc.database
 }
 
-// CollectTrustrootsData collects all Trustroots metrics
-func (mc *MongoCollector) CollectTrustrootsData(targetDate *time.Time) (*models.TrustrootsData, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
+func init() {
+	registerMongoMetric("messages", (*MongoCollector).collectMessagesPerDay,
+		func(data *models.KPIData, messages []models.DailyCount) {
+			data.Trustroots.MessagesPerDay = messages
+		})
+	registerMongoMetric("reviews", (*MongoCollector).collectReviewsPerDay,
+		func(data *models.KPIData, reviews []models.DailyReview) {
+			data.Trustroots.ReviewsPerDay = reviews
+		})
+	registerMongoMetric("threadVotes", (*MongoCollector).collectThreadVotesPerDay,
+		func(data *models.KPIData, votes []models.DailyVote) {
+			data.Trustroots.ThreadVotesPerDay = votes
+		})
+	registerMongoMetric("replyTimes", (*MongoCollector).collectTimeToFirstReplyPerDay,
+		func(data *models.KPIData, replyTimes []models.DailyTime) {
+			data.Trustroots.TimeToFirstReplyPerDay = replyTimes
+		})
+	registerMongoMetric("replyDistribution", (*MongoCollector).collectReplyDistributionPerDay,
+		func(data *models.KPIData, distribution []models.DailyReplyDistribution) {
+			data.Trustroots.ReplyDistributionPerDay = distribution
+		})
+	registerMongoMetric("conversationFunnel", (*MongoCollector).collectConversationFunnelPerDay,
+		func(data *models.KPIData, funnel []models.DailyConversationFunnel) {
+			data.Trustroots.ConversationFunnelPerDay = funnel
+		})
+	registerMongoMetric("offers", (*MongoCollector).collectOffers,
+		func(data *models.KPIData, offers *offerMetrics) {
+			data.Trustroots.OffersPerDay = offers.perDay
+			data.Trustroots.ActiveHosts = offers.activeHosts
+			data.Trustroots.OffersByCountry = offers.byCountry
+		})
+}
 
-	data := &models.TrustrootsData{}
+// registerMongoMetric registers a collector that runs a single Mongo metric
+// query and stores its result in the KPI output
+func registerMongoMetric[T any](name string, collect func(*MongoCollector, context.Context, Window) (T, error), apply func(*models.KPIData, T)) {
+	Register(name, func(deps Dependencies) (Collector, error) {
+		if deps.Mongo == nil {
+			return nil, fmt.Errorf("MongoDB is not configured")
+		}
 
-	// Collect messages per day
-	messages, err := mc.collectMessagesPerDay(ctx, targetDate)
-	if err != nil {
-		return nil, fmt.Errorf("failed to collect messages: %w", err)
-	}
-	data.MessagesPerDay = messages
+		return collectorFunc{
+			name: name,
+			collect: func(ctx context.Context, window Window) (func(*models.KPIData), error) {
+				ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+				defer cancel()
 
-	// Collect reviews per day
-	reviews, err := mc.collectReviewsPerDay(ctx, targetDate)
-	if err != nil {
-		return nil, fmt.Errorf("failed to collect reviews: %w", err)
-	}
-	data.ReviewsPerDay = reviews
+				start := time.Now()
+				result, err := collect(deps.Mongo, ctx, window)
+				metrics.ObserveMongoQuery(name, time.Since(start), err)
+				if err != nil {
+					return nil, err
+				}
 
-	// Collect thread votes per day
-	votes, err := mc.collectThreadVotesPerDay(ctx, targetDate)
-	if err != nil {
-		return nil, fmt.Errorf("failed to collect thread votes: %w", err)
-	}
-	data.ThreadVotesPerDay = votes
-
-	// Collect time to first reply per day
-	replyTimes, err := mc.collectTimeToFirstReplyPerDay(ctx, targetDate)
-	if err != nil {
-		return nil, fmt.Errorf("failed to collect reply times: %w", err)
-	}
-	data.TimeToFirstReplyPerDay = replyTimes
-
-	return data, nil
+				return func(data *models.KPIData) { apply(data, result) }, nil
+			},
+		}, nil
+	})
 }
 
 // CollectUsersWithNpubs counts users with valid npubs
-func (mc *MongoCollector) CollectUsersWithNpubs() (int, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+func (mc *MongoCollector) CollectUsersWithNpubs(ctx context.Context) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
 
 	filter := bson.M{
@@ -117,33 +142,17 @@ func (mc *MongoCollector) CollectUsersWithNpubs() (int, error) {
 	return int(count), nil
 }
 
-// collectMessagesPerDay aggregates messages by day for the last 7 days
-func (mc *MongoCollector) collectMessagesPerDay(ctx context.Context, targetDate *time.Time) ([]models.DailyCount, error) {
-	// Use target date or current date
-	var baseDate time.Time
-	if targetDate != nil {
-		baseDate = *targetDate
-	} else {
-		baseDate = time.Now()
-	}
-	sevenDaysAgo := baseDate.AddDate(0, 0, -7).Truncate(24 * time.Hour)
-
+// collectMessagesPerDay aggregates messages by period within the window
+func (mc *MongoCollector) collectMessagesPerDay(ctx context.Context, window Window) ([]models.DailyCount, error) {
 	pipeline := []bson.M{
 		{
 			"$match": bson.M{
-				"created": bson.M{
-					"$gte": sevenDaysAgo,
-				},
+				"created": window.MatchRange(),
 			},
 		},
 		{
 			"$group": bson.M{
-				"_id": bson.M{
-					"$dateToString": bson.M{
-						"format": "%Y-%m-%d",
-						"date":   "$created",
-					},
-				},
+				"_id":   window.DateToString("$created"),
 				"count": bson.M{"$sum": 1},
 			},
 		},
@@ -158,7 +167,7 @@ func (mc *MongoCollector) collectMessagesPerDay(ctx context.Context, targetDate
 	}
 	defer cursor.Close(ctx)
 
-	var results []models.DailyCount
+	byDate := make(map[string]models.DailyCount)
 	for cursor.Next(ctx) {
 		var result struct {
 			ID    string `bson:"_id"`
@@ -168,58 +177,61 @@ func (mc *MongoCollector) collectMessagesPerDay(ctx context.Context, targetDate
 			log.Printf("Error decoding message result: %v", err)
 			continue
 		}
-		results = append(results, models.DailyCount{
+		byDate[result.ID] = models.DailyCount{
 			Date:  result.ID,
 			Count: result.Count,
-		})
+		}
 	}
 
-	return results, cursor.Err()
+	// Periods without messages count as zero
+	return buildSeries(window, byDate, func(date string) models.DailyCount {
+		return models.DailyCount{Date: date}
+	}), cursor.Err()
 }
 
-// collectReviewsPerDay aggregates experiences by recommendation and day
-func (mc *MongoCollector) collectReviewsPerDay(ctx context.Context, targetDate *time.Time) ([]models.DailyReview, error) {
-	// Use target date or current date
-	var baseDate time.Time
-	if targetDate != nil {
-		baseDate = *targetDate
-	} else {
-		baseDate = time.Now()
-	}
-	sevenDaysAgo := baseDate.AddDate(0, 0, -7).Truncate(24 * time.Hour)
-
+// collectReviewsPerDay aggregates experiences by recommendation, interaction
+// and reciprocation per period
+func (mc *MongoCollector) collectReviewsPerDay(ctx context.Context, window Window) ([]models.DailyReview, error) {
 	pipeline := []bson.M{
 		{
 			"$match": bson.M{
-				"created": bson.M{
-					"$gte": sevenDaysAgo,
-				},
-				"recommend": bson.M{
-					"$in": []string{"yes", "no"},
-				},
+				"created": window.MatchRange(),
 			},
 		},
 		{
-			"$group": bson.M{
-				"_id": bson.M{
-					"date": bson.M{
-						"$dateToString": bson.M{
-							"format": "%Y-%m-%d",
-							"date":   "$created",
+			// The experience the other member wrote back, if any
+			"$lookup": bson.M{
+				"from": "experiences",
+				"let": bson.M{
+					"from": "$userFrom",
+					"to":   "$userTo",
+				},
+				"pipeline": []bson.M{
+					{
+						"$match": bson.M{
+							"$expr": bson.M{
+								"$and": []interface{}{
+									bson.M{"$eq": []interface{}{"$userFrom", "$$to"}},
+									bson.M{"$eq": []interface{}{"$userTo", "$$from"}},
+								},
+							},
 						},
 					},
-					"recommend": "$recommend",
+					{"$limit": 1},
+					{"$project": bson.M{"created": 1}},
 				},
-				"count": bson.M{"$sum": 1},
+				"as": "response",
 			},
 		},
 		{
 			"$group": bson.M{
-				"_id": "$_id.date",
-				"reviews": bson.M{
+				"_id": window.DateToString("$created"),
+				"experiences": bson.M{
 					"$push": bson.M{
-						"recommend": "$_id.recommend",
-						"count":     "$count",
+						"created":      "$created",
+						"recommend":    "$recommend",
+						"interactions": "$interactions",
+						"responded":    bson.M{"$arrayElemAt": []interface{}{"$response.created", 0}},
 					},
 				},
 			},
@@ -235,14 +247,20 @@ func (mc *MongoCollector) collectReviewsPerDay(ctx context.Context, targetDate *
 	}
 	defer cursor.Close(ctx)
 
-	var results []models.DailyReview
+	byDate := make(map[string]models.DailyReview)
 	for cursor.Next(ctx) {
 		var result struct {
-			ID      string `bson:"_id"`
-			Reviews []struct {
-				Recommend string `bson:"recommend"`
-				Count     int    `bson:"count"`
-			} `bson:"reviews"`
+			ID          string `bson:"_id"`
+			Experiences []struct {
+				Created      time.Time `bson:"created"`
+				Recommend    string    `bson:"recommend"`
+				Interactions struct {
+					Met        bool `bson:"met"`
+					HostedMe   bool `bson:"hostedMe"`
+					HostedThem bool `bson:"hostedThem"`
+				} `bson:"interactions"`
+				Responded *time.Time `bson:"responded"`
+			} `bson:"experiences"`
 		}
 		if err := cursor.Decode(&result); err != nil {
 			log.Printf("Error decoding review result: %v", err)
@@ -250,61 +268,73 @@ func (mc *MongoCollector) collectReviewsPerDay(ctx context.Context, targetDate *
 		}
 
 		review := models.DailyReview{Date: result.ID}
-		for _, r := range result.Reviews {
-			if r.Recommend == "yes" {
-				review.Positive = r.Count
-			} else if r.Recommend == "no" {
-				review.Negative = r.Count
+		var reciprocationTimes []int64
+		for _, experience := range result.Experiences {
+			switch experience.Recommend {
+			case "yes":
+				review.Positive++
+			case "no":
+				review.Negative++
+			default:
+				review.Unknown++
+			}
+
+			if experience.Interactions.HostedThem {
+				review.Hosted++
+			}
+			if experience.Interactions.HostedMe {
+				review.Guest++
+			}
+			if experience.Interactions.Met {
+				review.Met++
+			}
+
+			if experience.Responded != nil {
+				review.Reciprocated++
+				gap := experience.Responded.Sub(experience.Created)
+				if gap < 0 {
+					gap = -gap
+				}
+				reciprocationTimes = append(reciprocationTimes, gap.Milliseconds())
 			}
 		}
-		results = append(results, review)
+
+		review.ReciprocatedShare = ratio(review.Reciprocated, len(result.Experiences))
+		review.MedianReciprocationMs = percentile(reciprocationTimes, 50)
+		byDate[result.ID] = review
 	}
 
-	return results, cursor.Err()
+	// Periods without experiences count as zero
+	return buildSeries(window, byDate, func(date string) models.DailyReview {
+		return models.DailyReview{Date: date}
+	}), cursor.Err()
 }
 
-// collectThreadVotesPerDay aggregates reference thread votes by day
-func (mc *MongoCollector) collectThreadVotesPerDay(ctx context.Context, targetDate *time.Time) ([]models.DailyVote, error) {
-	// Use target date or current date
-	var baseDate time.Time
-	if targetDate != nil {
-		baseDate = *targetDate
-	} else {
-		baseDate = time.Now()
-	}
-	sevenDaysAgo := baseDate.AddDate(0, 0, -7).Truncate(24 * time.Hour)
-
+// collectThreadVotesPerDay aggregates reference thread votes by period
+func (mc *MongoCollector) collectThreadVotesPerDay(ctx context.Context, window Window) ([]models.DailyVote, error) {
 	pipeline := []bson.M{
 		{
 			"$match": bson.M{
-				"created": bson.M{
-					"$gte": sevenDaysAgo,
-				},
+				"created": window.MatchRange(),
 			},
 		},
 		{
 			"$group": bson.M{
-				"_id": bson.M{
-					"date": bson.M{
-						"$dateToString": bson.M{
-							"format": "%Y-%m-%d",
-							"date":   "$created",
-						},
-					},
-					"reference": "$reference",
-				},
-				"count": bson.M{"$sum": 1},
+				"_id":       window.DateToString("$created"),
+				"total":     bson.M{"$sum": 1},
+				"upvotes":   bson.M{"$sum": bson.M{"$cond": []interface{}{bson.M{"$eq": []interface{}{"$reference", "yes"}}, 1, 0}}},
+				"downvotes": bson.M{"$sum": bson.M{"$cond": []interface{}{bson.M{"$eq": []interface{}{"$reference", "no"}}, 1, 0}}},
+				"threads":   bson.M{"$addToSet": "$thread"},
+				"voters":    bson.M{"$addToSet": "$userFrom"},
 			},
 		},
 		{
-			"$group": bson.M{
-				"_id": "$_id.date",
-				"votes": bson.M{
-					"$push": bson.M{
-						"reference": "$_id.reference",
-						"count":     "$count",
-					},
-				},
+			"$project": bson.M{
+				"total":     1,
+				"upvotes":   1,
+				"downvotes": 1,
+				"threads":   bson.M{"$size": "$threads"},
+				"voters":    bson.M{"$size": "$voters"},
 			},
 		},
 		{
@@ -318,51 +348,51 @@ func (mc *MongoCollector) collectThreadVotesPerDay(ctx context.Context, targetDa
 	}
 	defer cursor.Close(ctx)
 
-	var results []models.DailyVote
+	byDate := make(map[string]models.DailyVote)
 	for cursor.Next(ctx) {
 		var result struct {
-			ID    string `bson:"_id"`
-			Votes []struct {
-				Reference string `bson:"reference"`
-				Count     int    `bson:"count"`
-			} `bson:"votes"`
+			ID        string `bson:"_id"`
+			Total     int    `bson:"total"`
+			Upvotes   int    `bson:"upvotes"`
+			Downvotes int    `bson:"downvotes"`
+			Threads   int    `bson:"threads"`
+			Voters    int    `bson:"voters"`
 		}
 		if err := cursor.Decode(&result); err != nil {
 			log.Printf("Error decoding vote result: %v", err)
 			continue
 		}
 
-		vote := models.DailyVote{Date: result.ID}
-		for _, v := range result.Votes {
-			if v.Reference == "yes" {
-				vote.Upvotes = v.Count
-			} else if v.Reference == "no" {
-				vote.Downvotes = v.Count
-			}
+		vote := models.DailyVote{
+			Date:      result.ID,
+			Upvotes:   result.Upvotes,
+			Downvotes: result.Downvotes,
+			Other:     result.Total - result.Upvotes - result.Downvotes,
+			Threads:   result.Threads,
+			Voters:    result.Voters,
 		}
-		results = append(results, vote)
+		if vote.Other > 0 {
+			log.Printf("Found %d thread votes with a value other than yes or no on %s", vote.Other, result.ID)
+		}
+		if vote.Downvotes > 0 {
+			upDownRatio := float64(vote.Upvotes) / float64(vote.Downvotes)
+			vote.UpDownRatio = &upDownRatio
+		}
+		byDate[result.ID] = vote
 	}
 
-	return results, cursor.Err()
+	// Periods without votes count as zero
+	return buildSeries(window, byDate, func(date string) models.DailyVote {
+		return models.DailyVote{Date: date}
+	}), cursor.Err()
 }
 
-// collectTimeToFirstReplyPerDay calculates average time to first reply
-func (mc *MongoCollector) collectTimeToFirstReplyPerDay(ctx context.Context, targetDate *time.Time) ([]models.DailyTime, error) {
-	// Use target date or current date
-	var baseDate time.Time
-	if targetDate != nil {
-		baseDate = *targetDate
-	} else {
-		baseDate = time.Now()
-	}
-	sevenDaysAgo := baseDate.AddDate(0, 0, -7).Truncate(24 * time.Hour)
-
+// collectTimeToFirstReplyPerDay calculates average time to first reply per period
+func (mc *MongoCollector) collectTimeToFirstReplyPerDay(ctx context.Context, window Window) ([]models.DailyTime, error) {
 	pipeline := []bson.M{
 		{
 			"$match": bson.M{
-				"firstMessageCreated": bson.M{
-					"$gte": sevenDaysAgo,
-				},
+				"firstMessageCreated": window.MatchRange(),
 				"timeToFirstReply": bson.M{
 					"$exists": true,
 					"$ne":     nil,
@@ -371,12 +401,7 @@ func (mc *MongoCollector) collectTimeToFirstReplyPerDay(ctx context.Context, tar
 		},
 		{
 			"$group": bson.M{
-				"_id": bson.M{
-					"$dateToString": bson.M{
-						"format": "%Y-%m-%d",
-						"date":   "$firstMessageCreated",
-					},
-				},
+				"_id":   window.DateToString("$firstMessageCreated"),
 				"avgMs": bson.M{"$avg": "$timeToFirstReply"},
 			},
 		},
@@ -391,7 +416,7 @@ func (mc *MongoCollector) collectTimeToFirstReplyPerDay(ctx context.Context, tar
 	}
 	defer cursor.Close(ctx)
 
-	var results []models.DailyTime
+	byDate := make(map[string]models.DailyTime)
 	for cursor.Next(ctx) {
 		var result struct {
 			ID    string  `bson:"_id"`
@@ -401,11 +426,418 @@ func (mc *MongoCollector) collectTimeToFirstReplyPerDay(ctx context.Context, tar
 			log.Printf("Error decoding reply time result: %v", err)
 			continue
 		}
-		results = append(results, models.DailyTime{
+		avgMs := int64(result.AvgMs)
+		byDate[result.ID] = models.DailyTime{
 			Date:  result.ID,
-			AvgMs: int64(result.AvgMs),
-		})
+			AvgMs: &avgMs,
+		}
+	}
+
+	// An average of no replies is undefined, so those periods are null
+	return buildSeries(window, byDate, func(date string) models.DailyTime {
+		return models.DailyTime{Date: date}
+	}), cursor.Err()
+}
+
+// collectReplyDistributionPerDay calculates reply time percentiles, the reply
+// rate and reply time buckets for the conversations started in each period
+func (mc *MongoCollector) collectReplyDistributionPerDay(ctx context.Context, window Window) ([]models.DailyReplyDistribution, error) {
+	pipeline := []bson.M{
+		{
+			"$match": bson.M{
+				"firstMessageCreated": window.MatchRange(),
+			},
+		},
+		{
+			"$group": bson.M{
+				"_id":           window.DateToString("$firstMessageCreated"),
+				"conversations": bson.M{"$sum": 1},
+				// Conversations without a reply are marked with -1
+				"replyTimes": bson.M{"$push": bson.M{"$ifNull": []interface{}{"$timeToFirstReply", -1}}},
+			},
+		},
+		{
+			"$sort": bson.M{"_id": 1},
+		},
+	}
+
+	cursor, err := mc.database.Collection("messagestats").Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	byDate := make(map[string]models.DailyReplyDistribution)
+	for cursor.Next(ctx) {
+		var result struct {
+			ID            string    `bson:"_id"`
+			Conversations int       `bson:"conversations"`
+			ReplyTimes    []float64 `bson:"replyTimes"`
+		}
+		if err := cursor.Decode(&result); err != nil {
+			log.Printf("Error decoding reply distribution result: %v", err)
+			continue
+		}
+
+		distribution := models.DailyReplyDistribution{
+			Date:          result.ID,
+			Conversations: result.Conversations,
+		}
+		var replyTimes []int64
+		for _, ms := range result.ReplyTimes {
+			reply := time.Duration(ms) * time.Millisecond
+			switch {
+			case ms < 0:
+				distribution.Never++
+				continue
+			case reply < time.Hour:
+				distribution.Under1h++
+			case reply < 24*time.Hour:
+				distribution.Under1d++
+			case reply < 7*24*time.Hour:
+				distribution.Under1w++
+			default:
+				distribution.Over1w++
+			}
+			replyTimes = append(replyTimes, int64(ms))
+		}
+
+		distribution.Replied = len(replyTimes)
+		distribution.ReplyRate = ratio(distribution.Replied, distribution.Conversations)
+		distribution.MedianMs = percentile(replyTimes, 50)
+		distribution.P75Ms = percentile(replyTimes, 75)
+		distribution.P90Ms = percentile(replyTimes, 90)
+		byDate[result.ID] = distribution
+	}
+
+	// Periods without conversations have zero counts and null rates
+	return buildSeries(window, byDate, func(date string) models.DailyReplyDistribution {
+		return models.DailyReplyDistribution{Date: date}
+	}), cursor.Err()
+}
+
+// collectConversationFunnelPerDay follows the conversations started in each
+// period to their first reply and to a meeting experience between the members
+func (mc *MongoCollector) collectConversationFunnelPerDay(ctx context.Context, window Window) ([]models.DailyConversationFunnel, error) {
+	pipeline := []bson.M{
+		{
+			"$match": bson.M{
+				"firstMessageCreated": window.MatchRange(),
+			},
+		},
+		// The first experience either member wrote about the other after the
+		// first message, if they met or hosted each other. Each direction is
+		// looked up on its own, so both can use the index on the user pair.
+		lookupMeetingExperience("$firstMessageUserFrom", "$firstMessageUserTo", "experienceFrom"),
+		lookupMeetingExperience("$firstMessageUserTo", "$firstMessageUserFrom", "experienceTo"),
+		{
+			"$group": bson.M{
+				"_id": window.DateToString("$firstMessageCreated"),
+				"conversations": bson.M{
+					"$push": bson.M{
+						"timeToFirstReply":  "$timeToFirstReply",
+						"firstReplyCreated": "$firstReplyCreated",
+						"experienceCreated": bson.M{
+							"$min": []interface{}{
+								bson.M{"$arrayElemAt": []interface{}{"$experienceFrom.created", 0}},
+								bson.M{"$arrayElemAt": []interface{}{"$experienceTo.created", 0}},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			"$sort": bson.M{"_id": 1},
+		},
+	}
+
+	cursor, err := mc.database.Collection("messagestats").Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	byDate := make(map[string]models.DailyConversationFunnel)
+	for cursor.Next(ctx) {
+		var result struct {
+			ID            string `bson:"_id"`
+			Conversations []struct {
+				TimeToFirstReply  *float64   `bson:"timeToFirstReply"`
+				FirstReplyCreated *time.Time `bson:"firstReplyCreated"`
+				ExperienceCreated *time.Time `bson:"experienceCreated"`
+			} `bson:"conversations"`
+		}
+		if err := cursor.Decode(&result); err != nil {
+			log.Printf("Error decoding conversation funnel result: %v", err)
+			continue
+		}
+
+		funnel := models.DailyConversationFunnel{
+			Date:          result.ID,
+			FirstContacts: len(result.Conversations),
+		}
+		var replyTimes, meetingTimes []int64
+		for _, conversation := range result.Conversations {
+			if conversation.TimeToFirstReply != nil {
+				funnel.Replied++
+				replyTimes = append(replyTimes, int64(*conversation.TimeToFirstReply))
+			}
+			if conversation.ExperienceCreated != nil {
+				funnel.Met++
+				if conversation.FirstReplyCreated != nil && !conversation.ExperienceCreated.Before(*conversation.FirstReplyCreated) {
+					meetingTimes = append(meetingTimes, conversation.ExperienceCreated.Sub(*conversation.FirstReplyCreated).Milliseconds())
+				}
+			}
+		}
+
+		funnel.ReplyShare = ratio(funnel.Replied, funnel.FirstContacts)
+		funnel.MetShare = ratio(funnel.Met, funnel.FirstContacts)
+		funnel.MedianReplyMs = percentile(replyTimes, 50)
+		funnel.MedianReplyToMeetingMs = percentile(meetingTimes, 50)
+		byDate[result.ID] = funnel
+	}
+
+	// Periods without first contacts have zero counts and null shares
+	return buildSeries(window, byDate, func(date string) models.DailyConversationFunnel {
+		return models.DailyConversationFunnel{Date: date}
+	}), cursor.Err()
+}
+
+// lookupMeetingExperience looks up the first experience the member in the from
+// field wrote about the member in the to field since the first message, if
+// they met or hosted each other
+func lookupMeetingExperience(from, to, as string) bson.M {
+	return bson.M{
+		"$lookup": bson.M{
+			"from": "experiences",
+			"let": bson.M{
+				"from":    from,
+				"to":      to,
+				"created": "$firstMessageCreated",
+			},
+			"pipeline": []bson.M{
+				{
+					"$match": bson.M{
+						"$expr": bson.M{
+							"$and": []interface{}{
+								bson.M{"$eq": []interface{}{"$userFrom", "$$from"}},
+								bson.M{"$eq": []interface{}{"$userTo", "$$to"}},
+								bson.M{"$gte": []interface{}{"$created", "$$created"}},
+							},
+						},
+						"$or": []bson.M{
+							{"interactions.met": true},
+							{"interactions.hostedMe": true},
+							{"interactions.hostedThem": true},
+						},
+					},
+				},
+				{"$sort": bson.M{"created": 1}},
+				{"$limit": 1},
+				{"$project": bson.M{"created": 1}},
+			},
+			"as": as,
+		},
+	}
+}
+
+// offerMetrics are the hosting metrics collected from offers
+type offerMetrics struct {
+	perDay      []models.DailyOffers
+	activeHosts int
+	byCountry   []models.CountryCount
+}
+
+// collectOffers collects new and updated offers per period together with the
+// current number of active hosts per country. The active hosts are a snapshot,
+// so they are left out for past windows.
+func (mc *MongoCollector) collectOffers(ctx context.Context, window Window) (*offerMetrics, error) {
+	perDay, err := mc.collectOffersPerDay(ctx, window)
+	if err != nil {
+		return nil, err
+	}
+	if window.Past() {
+		return &offerMetrics{perDay: perDay}, nil
+	}
+
+	byCountry, err := mc.collectActiveHostsByCountry(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect active hosts: %w", err)
 	}
 
-	return results, cursor.Err()
+	activeHosts := 0
+	for _, country := range byCountry {
+		activeHosts += country.Count
+	}
+
+	return &offerMetrics{
+		perDay:      perDay,
+		activeHosts: activeHosts,
+		byCountry:   byCountry,
+	}, nil
+}
+
+// collectOffersPerDay counts offers created or updated in each period by type
+// and status
+func (mc *MongoCollector) collectOffersPerDay(ctx context.Context, window Window) ([]models.DailyOffers, error) {
+	pipeline := []bson.M{
+		{
+			"$match": bson.M{
+				"$or": []bson.M{
+					{"created": window.MatchRange()},
+					{"updated": window.MatchRange()},
+				},
+			},
+		},
+		{
+			"$group": bson.M{
+				"_id": bson.M{
+					"created": window.DateToString("$created"),
+					"updated": window.DateToString("$updated"),
+					"type":    "$type",
+					"status":  "$status",
+				},
+				"count": bson.M{"$sum": 1},
+			},
+		},
+	}
+
+	cursor, err := mc.database.Collection("offers").Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	byDate := make(map[string]models.DailyOffers)
+	for cursor.Next(ctx) {
+		var result struct {
+			ID struct {
+				Created string `bson:"created"`
+				Updated string `bson:"updated"`
+				Type    string `bson:"type"`
+				Status  string `bson:"status"`
+			} `bson:"_id"`
+			Count int `bson:"count"`
+		}
+		if err := cursor.Decode(&result); err != nil {
+			log.Printf("Error decoding offer result: %v", err)
+			continue
+		}
+
+		// An offer created in the window counts as new on that day, and as
+		// updated on a later day it was last edited on
+		created := byDate[result.ID.Created]
+		created.Date = result.ID.Created
+		addOffers(&created.NewHostYes, &created.NewHostMaybe, &created.NewHostNo, &created.NewMeet, result.ID.Type, result.ID.Status, result.Count)
+		byDate[result.ID.Created] = created
+
+		if result.ID.Updated != "" && result.ID.Updated != result.ID.Created {
+			updated := byDate[result.ID.Updated]
+			updated.Date = result.ID.Updated
+			addOffers(&updated.UpdatedHostYes, &updated.UpdatedHostMaybe, &updated.UpdatedHostNo, &updated.UpdatedMeet, result.ID.Type, result.ID.Status, result.Count)
+			byDate[result.ID.Updated] = updated
+		}
+	}
+
+	// Periods without offers count as zero, and creation dates before the
+	// window are dropped
+	return buildSeries(window, byDate, func(date string) models.DailyOffers {
+		return models.DailyOffers{Date: date}
+	}), cursor.Err()
+}
+
+// addOffers adds count to the counter matching the offer type and host status
+func addOffers(hostYes, hostMaybe, hostNo, meet *int, offerType, status string, count int) {
+	switch {
+	case offerType == "meet":
+		*meet += count
+	case status == "yes":
+		*hostYes += count
+	case status == "maybe":
+		*hostMaybe += count
+	case status == "no":
+		*hostNo += count
+	}
+}
+
+// collectActiveHostsByCountry counts host offers accepting guests by the
+// country their host lives in, most hosts first
+func (mc *MongoCollector) collectActiveHostsByCountry(ctx context.Context) ([]models.CountryCount, error) {
+	pipeline := []bson.M{
+		{
+			"$match": bson.M{
+				"type":   "host",
+				"status": bson.M{"$in": []string{"yes", "maybe"}},
+			},
+		},
+		{
+			// Only fetch where the host lives instead of whole user documents
+			"$lookup": bson.M{
+				"from": "users",
+				"let":  bson.M{"user": "$user"},
+				"pipeline": []bson.M{
+					{"$match": bson.M{"$expr": bson.M{"$eq": []interface{}{"$_id", "$$user"}}}},
+					{"$project": bson.M{"locationLiving": 1}},
+				},
+				"as": "host",
+			},
+		},
+		{
+			"$group": bson.M{
+				"_id":   bson.M{"$arrayElemAt": []interface{}{"$host.locationLiving", 0}},
+				"count": bson.M{"$sum": 1},
+			},
+		},
+	}
+
+	cursor, err := mc.database.Collection("offers").Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	counts := make(map[string]int)
+	for cursor.Next(ctx) {
+		var result struct {
+			ID    string `bson:"_id"`
+			Count int    `bson:"count"`
+		}
+		if err := cursor.Decode(&result); err != nil {
+			log.Printf("Error decoding host country result: %v", err)
+			continue
+		}
+		counts[countryFromLocation(result.ID)] += result.Count
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, err
+	}
+
+	return sortedCountryCounts(counts), nil
+}
+
+// countryFromLocation extracts the country from a free-form location such as
+// "Berlin, Germany", which is how users store where they live
+func countryFromLocation(location string) string {
+	parts := strings.Split(location, ",")
+	country := strings.TrimSpace(parts[len(parts)-1])
+	if country == "" {
+		return "Unknown"
+	}
+	return country
+}
+
+// sortedCountryCounts converts counts by country to a list, highest count first
+func sortedCountryCounts(counts map[string]int) []models.CountryCount {
+	result := make([]models.CountryCount, 0, len(counts))
+	for country, count := range counts {
+		result = append(result, models.CountryCount{Country: country, Count: count})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Count != result[j].Count {
+			return result[i].Count > result[j].Count
+		}
+		return result[i].Country < result[j].Country
+	})
+	return result
 }
@@ -0,0 +1,141 @@
+package collectors
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+
+	"kpi.trustroots.org/models"
+)
+
+// activitySource is a collection and date field that mark a user as active
+type activitySource struct {
+	collection string
+	field      string // Date of the activity
+	user       string // Expression of the active user's ID
+}
+
+// activitySources are the actions counted as activity. Users only keep their
+// last seen and updated dates, so older visits are not counted.
+var activitySources = []activitySource{
+	{collection: "messages", field: "created", user: "$userFrom"},
+	{collection: "experiences", field: "created", user: "$userFrom"},
+	{collection: "referencethreads", field: "created", user: "$userFrom"},
+	{collection: "users", field: "seen", user: "$_id"},
+	{collection: "users", field: "updated", user: "$_id"},
+}
+
+func init() {
+	registerMongoMetric("activeUsers", (*MongoCollector).collectActiveUsersPerDay,
+		func(data *models.KPIData, activeUsers []models.DailyActiveUsers) {
+			data.Trustroots.ActiveUsersPerDay = activeUsers
+		})
+}
+
+// collectActiveUsersPerDay counts distinct active users per day, over the 7
+// and 30 days ending on each day, and the resulting stickiness
+func (mc *MongoCollector) collectActiveUsersPerDay(ctx context.Context, window Window) ([]models.DailyActiveUsers, error) {
+	// Monthly active users need the 29 days before the window as well
+	days := Window{
+		Since:       window.dayStart(window.Since, -29),
+		Until:       window.Until,
+		Granularity: GranularityDay,
+		Location:    window.Location,
+	}
+
+	activeByDay := make(map[string]map[string]bool)
+	for _, source := range activitySources {
+		if err := mc.collectActivity(ctx, days, source, activeByDay); err != nil {
+			return nil, fmt.Errorf("failed to collect %s activity: %w", source.collection, err)
+		}
+	}
+
+	byDate := make(map[string]models.DailyActiveUsers)
+	for day := window.Since.In(days.location()); day.Before(window.Until); day = day.AddDate(0, 0, 1) {
+		dau := len(activeByDay[days.Key(day)])
+		mau := countActive(activeByDay, days, day, 30)
+
+		// Later days overwrite earlier ones, so each period reports its last day
+		date := window.Key(day)
+		byDate[date] = models.DailyActiveUsers{
+			Date:       date,
+			DAU:        dau,
+			WAU:        countActive(activeByDay, days, day, 7),
+			MAU:        mau,
+			Stickiness: ratio(dau, mau),
+		}
+	}
+
+	return buildSeries(window, byDate, func(date string) models.DailyActiveUsers {
+		return models.DailyActiveUsers{Date: date}
+	}), nil
+}
+
+// collectActivity adds the users active in each period of the window according
+// to a single source to activeByDay
+func (mc *MongoCollector) collectActivity(ctx context.Context, window Window, source activitySource, activeByDay map[string]map[string]bool) error {
+	pipeline := []bson.M{
+		{
+			"$match": bson.M{
+				source.field: window.MatchRange(),
+			},
+		},
+		{
+			"$group": bson.M{
+				"_id": bson.M{
+					"date": window.DateToString("$" + source.field),
+					"user": bson.M{"$toString": source.user},
+				},
+			},
+		},
+		{
+			"$group": bson.M{
+				"_id":   "$_id.date",
+				"users": bson.M{"$push": "$_id.user"},
+			},
+		},
+	}
+
+	cursor, err := mc.database.Collection(source.collection).Aggregate(ctx, pipeline)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var result struct {
+			ID    string   `bson:"_id"`
+			Users []string `bson:"users"`
+		}
+		if err := cursor.Decode(&result); err != nil {
+			log.Printf("Error decoding %s activity result: %v", source.collection, err)
+			continue
+		}
+
+		active, exists := activeByDay[result.ID]
+		if !exists {
+			active = make(map[string]bool)
+			activeByDay[result.ID] = active
+		}
+		for _, user := range result.Users {
+			active[user] = true
+		}
+	}
+
+	return cursor.Err()
+}
+
+// countActive counts the distinct users active in the given number of days
+// ending on day
+func countActive(activeByDay map[string]map[string]bool, days Window, day time.Time, length int) int {
+	users := make(map[string]bool)
+	for i := 0; i < length; i++ {
+		for user := range activeByDay[days.Key(day.AddDate(0, 0, -i))] {
+			users[user] = true
+		}
+	}
+	return len(users)
+}
@@ -0,0 +1,142 @@
+package collectors
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"go.mongodb.org/mongo-driver/bson"
+
+	"kpi.trustroots.org/models"
+)
+
+func init() {
+	registerMongoMetric("signups", (*MongoCollector).collectSignupsPerDay,
+		func(data *models.KPIData, signups []models.DailySignups) {
+			data.Trustroots.SignupsPerDay = signups
+		})
+}
+
+// collectSignupsPerDay aggregates new users by signup period with the share
+// that confirmed their email and completed their profile, plus the npubs set
+func (mc *MongoCollector) collectSignupsPerDay(ctx context.Context, window Window) ([]models.DailySignups, error) {
+	pipeline := []bson.M{
+		{
+			"$match": bson.M{
+				"created": window.MatchRange(),
+			},
+		},
+		{
+			"$group": bson.M{
+				"_id":     window.DateToString("$created"),
+				"signups": bson.M{"$sum": 1},
+				// Users become public once they confirm their email address
+				"confirmed": bson.M{"$sum": bson.M{"$cond": []interface{}{bson.M{"$eq": []interface{}{"$public", true}}, 1, 0}}},
+				"complete": bson.M{"$sum": bson.M{"$cond": []interface{}{
+					bson.M{"$and": []interface{}{
+						nonEmptyString("$description"),
+						nonEmptyString("$locationLiving"),
+						bson.M{"$not": []interface{}{bson.M{"$in": []interface{}{bson.M{"$ifNull": []interface{}{"$avatarSource", "none"}}, []string{"none", ""}}}}},
+					}},
+					1, 0,
+				}}},
+			},
+		},
+		{
+			"$sort": bson.M{"_id": 1},
+		},
+	}
+
+	cursor, err := mc.database.Collection("users").Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	byDate := make(map[string]models.DailySignups)
+	for cursor.Next(ctx) {
+		var result struct {
+			ID        string `bson:"_id"`
+			Signups   int    `bson:"signups"`
+			Confirmed int    `bson:"confirmed"`
+			Complete  int    `bson:"complete"`
+		}
+		if err := cursor.Decode(&result); err != nil {
+			log.Printf("Error decoding signup result: %v", err)
+			continue
+		}
+		byDate[result.ID] = models.DailySignups{
+			Date:                  result.ID,
+			Signups:               result.Signups,
+			Confirmed:             result.Confirmed,
+			ConfirmedRate:         ratio(result.Confirmed, result.Signups),
+			CompleteProfiles:      result.Complete,
+			ProfileCompletionRate: ratio(result.Complete, result.Signups),
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, err
+	}
+
+	npubsSet, err := mc.countNpubsSetPerDay(ctx, window)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count npubs set: %w", err)
+	}
+	for date, count := range npubsSet {
+		signups := byDate[date]
+		signups.Date = date
+		signups.NpubsSet = count
+		byDate[date] = signups
+	}
+
+	// Periods without signups have zero counts and null rates
+	return buildSeries(window, byDate, func(date string) models.DailySignups {
+		return models.DailySignups{Date: date}
+	}), nil
+}
+
+// countNpubsSetPerDay counts users with an npub by the period their profile was
+// last updated in, as an approximation of when the npub was set
+func (mc *MongoCollector) countNpubsSetPerDay(ctx context.Context, window Window) (map[string]int, error) {
+	pipeline := []bson.M{
+		{
+			"$match": bson.M{
+				"updated":   window.MatchRange(),
+				"nostrNpub": bson.M{"$regex": "^npub1"},
+			},
+		},
+		{
+			"$group": bson.M{
+				"_id":   window.DateToString("$updated"),
+				"count": bson.M{"$sum": 1},
+			},
+		},
+	}
+
+	cursor, err := mc.database.Collection("users").Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	counts := make(map[string]int)
+	for cursor.Next(ctx) {
+		var result struct {
+			ID    string `bson:"_id"`
+			Count int    `bson:"count"`
+		}
+		if err := cursor.Decode(&result); err != nil {
+			log.Printf("Error decoding npub result: %v", err)
+			continue
+		}
+		counts[result.ID] = result.Count
+	}
+
+	return counts, cursor.Err()
+}
+
+// nonEmptyString returns a Mongo expression that is true when the field is a
+// non-empty string
+func nonEmptyString(field string) bson.M {
+	return bson.M{"$gt": []interface{}{bson.M{"$strLenCP": bson.M{"$ifNull": []interface{}{field, ""}}}, 0}}
+}
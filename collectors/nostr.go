@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"sync"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
@@ -12,46 +13,85 @@ import (
 
 	"github.com/nbd-wtf/go-nostr"
 	"github.com/nbd-wtf/go-nostr/nip19"
+	"kpi.trustroots.org/metrics"
 	"kpi.trustroots.org/models"
 )
 
+// maxRelayPages stops paginating a relay that keeps returning new events
+const maxRelayPages = 1000
+
+// NostrOptions configures how relays are queried
+type NostrOptions struct {
+	ConnectTimeout  time.Duration      // Deadline for connecting to a relay
+	QueryTimeout    time.Duration      // Deadline for each query page
+	AuthorBatchSize int                // Maximum number of authors per filter
+	PageLimit       int                // Maximum number of events requested per page
+	Kinds           []models.NostrKind // Kind catalogue, DefaultNostrKinds if empty
+}
+
 // NostrCollector handles Nostr relay data collection
 type NostrCollector struct {
-	relays []string
-	mongo  *mongo.Database
+	relays  []string
+	mongo   *mongo.Database
+	options NostrOptions
 }
 
-// NewNostrCollector creates a new Nostr collector
-func NewNostrCollector(relays []string, mongoDB *mongo.Database) *NostrCollector {
+// NewNostrCollector creates a new Nostr collector. Each relay gets its own
+// connect and query deadlines so one slow relay cannot hold up the others.
+func NewNostrCollector(relays []string, mongoDB *mongo.Database, options NostrOptions) *NostrCollector {
+	if len(options.Kinds) == 0 {
+		options.Kinds = DefaultNostrKinds()
+	}
 	return &NostrCollector{
-		relays: relays,
-		mongo:  mongoDB,
+		relays:  relays,
+		mongo:   mongoDB,
+		options: options,
 	}
 }
 
-// CollectNostrootsData collects all Nostr-related metrics
-func (nc *NostrCollector) CollectNostrootsData(targetDate *time.Time) (*models.NostrootsData, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
-	defer cancel()
+func init() {
+	Register("nostr", func(deps Dependencies) (Collector, error) {
+		if deps.Mongo == nil {
+			return nil, fmt.Errorf("MongoDB is not configured")
+		}
+		return NewNostrCollector(deps.Relays, deps.Mongo.GetDatabase(), deps.Nostr), nil
+	})
+}
 
-	data := &models.NostrootsData{}
+// Name returns the collector name
+func (nc *NostrCollector) Name() string {
+	return "nostr"
+}
 
-	// Get npubs for querying relays
-	npubs, err := nc.getNpubsFromUsers(ctx)
+// Collect gathers the Nostroots metrics for the window
+func (nc *NostrCollector) Collect(ctx context.Context, window Window) (func(*models.KPIData), error) {
+	nostrootsData, err := nc.CollectNostrootsData(ctx, window)
+	if err != nil {
+		return nil, err
+	}
+	return func(data *models.KPIData) { data.Nostroots = *nostrootsData }, nil
+}
+
+// CollectNostrootsData collects all Nostr-related metrics for the window
+func (nc *NostrCollector) CollectNostrootsData(ctx context.Context, window Window) (*models.NostrootsData, error) {
+	data := &models.NostrootsData{Kinds: nc.options.Kinds}
+
+	// Get npubs for querying relays. Relay queries have their own per-page
+	// deadlines, since the number of pages grows with the number of users.
+	mongoCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	start := time.Now()
+	npubs, err := nc.getNpubsFromUsers(mongoCtx)
+	metrics.ObserveMongoQuery(nc.Name(), time.Since(start), err)
+	cancel()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get npubs: %w", err)
 	}
 
 	// Query relays for events and get valid npub count
-	validNpubs, activePosters, notesByKind, err := nc.queryRelaysForEvents(ctx, npubs, targetDate)
-	if err != nil {
+	if err := nc.queryRelaysForEvents(ctx, npubs, window, data); err != nil {
 		return nil, fmt.Errorf("failed to query relays: %w", err)
 	}
 
-	data.UsersWithNpubs = validNpubs
-	data.ActivePosters = activePosters
-	data.NotesByKindPerDay = notesByKind
-
 	return data, nil
 }
 
@@ -89,10 +129,12 @@ func (nc *NostrCollector) getNpubsFromUsers(ctx context.Context) ([]string, erro
 	return npubs, cursor.Err()
 }
 
-// queryRelaysForEvents queries all relays for events by the given npubs
-func (nc *NostrCollector) queryRelaysForEvents(ctx context.Context, npubs []string, targetDate *time.Time) (int, int, []models.DailyNotes, error) {
+// queryRelaysForEvents queries all relays for events by the given npubs and
+// fills in the Nostroots metrics
+func (nc *NostrCollector) queryRelaysForEvents(ctx context.Context, npubs []string, window Window, data *models.NostrootsData) error {
+	data.NotesByKindPerDay = []models.DailyNotes{}
 	if len(npubs) == 0 {
-		return 0, 0, []models.DailyNotes{}, nil
+		return nil
 	}
 
 	log.Printf("Querying %d npubs from %d relays (real implementation)", len(npubs), len(nc.relays))
@@ -116,104 +158,251 @@ func (nc *NostrCollector) queryRelaysForEvents(ctx context.Context, npubs []stri
 	}
 
 	log.Printf("Found %d valid npubs out of %d total entries", validNpubs, len(npubs))
+	data.UsersWithNpubs = validNpubs
 
 	if len(pubkeys) == 0 {
 		log.Printf("No valid pubkeys found from %d npubs", len(npubs))
-		return validNpubs, 0, []models.DailyNotes{}, nil
+		return nil
 	}
 
 	// Query relays for events
-	events, err := nc.queryRelays(ctx, pubkeys, targetDate)
-	if err != nil {
-		log.Printf("Error querying relays: %v", err)
-		// Return empty data if relay querying fails
-		return validNpubs, 0, []models.DailyNotes{}, err
+	eventsByRelay, relayResults, rejections := nc.queryRelays(ctx, pubkeys, window)
+	data.Relays = relayResults
+
+	// Report events that failed verification
+	data.DataQuality.PerRelay = rejections
+	for i, events := range eventsByRelay {
+		data.DataQuality.Verified += len(events)
+		data.DataQuality.Rejected += rejections[i].Total()
+	}
+	if data.DataQuality.Rejected > 0 {
+		log.Printf("Rejected %d events that failed verification", data.DataQuality.Rejected)
+	}
+
+	failedRelays := 0
+	for _, result := range relayResults {
+		if result.Error != "" {
+			failedRelays++
+		}
+	}
+	if failedRelays > 0 && failedRelays == len(relayResults) {
+		return fmt.Errorf("all %d relays failed", failedRelays)
 	}
 
+	// Count events mirrored on several relays only once
+	events, overlap := dedupeEvents(nc.relays, eventsByRelay)
+	data.RelayOverlap = overlap
+	log.Printf("Kept %d of %d events after removing %d duplicates and %d replaced versions",
+		overlap.Counted, overlap.Received, overlap.Duplicates, overlap.Collapsed)
+
 	// Process events to get active posters and notes by kind
-	activePosters, notesByKind := nc.processEvents(events, targetDate)
+	data.ActivePosters, data.NotesByKindPerDay = nc.processEvents(events, window)
+
+	return nil
+}
+
+// queryRelays queries all configured relays in parallel and returns the
+// verified events, a result and the rejected events for each relay, in the
+// order of nc.relays
+func (nc *NostrCollector) queryRelays(ctx context.Context, pubkeys []string, window Window) ([][]*nostr.Event, []models.RelayResult, []models.RelayRejections) {
+	// Convert window to nostr timestamps
+	sinceTimestamp := nostr.Timestamp(window.Since.Unix())
+	untilTimestamp := nostr.Timestamp(window.Until.Unix())
+
+	// Create filters for the time range, splitting the authors into batches
+	// since relays may reject filters with too many authors
+	var filters []nostr.Filter
+	for _, batch := range batchStrings(pubkeys, nc.options.AuthorBatchSize) {
+		filters = append(filters, nostr.Filter{
+			Authors: batch,
+			Since:   &sinceTimestamp,
+			Until:   &untilTimestamp,
+			Kinds:   queryKinds(nc.options.Kinds),
+		})
+	}
+
+	authors := make(map[string]bool, len(pubkeys))
+	for _, pubkey := range pubkeys {
+		authors[pubkey] = true
+	}
+
+	results := make([]models.RelayResult, len(nc.relays))
+	eventsByRelay := make([][]*nostr.Event, len(nc.relays))
+	rejections := make([]models.RelayRejections, len(nc.relays))
+
+	var wg sync.WaitGroup
+	for i, relayURL := range nc.relays {
+		wg.Add(1)
+		go func(i int, relayURL string) {
+			defer wg.Done()
+
+			log.Printf("Querying relay: %s", relayURL)
+			rejections[i].URL = relayURL
+			start := time.Now()
+			events, truncated, err := nc.queryRelay(ctx, relayURL, filters)
+			metrics.ObserveRelay(relayURL, "query", err)
+
+			results[i] = models.RelayResult{
+				URL:              relayURL,
+				Events:           len(events),
+				LatencyMs:        time.Since(start).Milliseconds(),
+				TruncatedSeconds: truncated.seconds,
+				PageLimitReached: truncated.pageLimit,
+			}
+			if err != nil {
+				log.Printf("Failed to query relay %s: %v", relayURL, err)
+				results[i].Error = err.Error()
+				return
+			}
 
-	return validNpubs, activePosters, notesByKind, nil
+			log.Printf("Found %d events from relay %s", len(events), relayURL)
+			if truncated.seconds > 0 {
+				log.Printf("Relay %s may be missing events in %d seconds with more events than fit a page", relayURL, truncated.seconds)
+			}
+			if truncated.pageLimit {
+				log.Printf("Relay %s is missing events older than its last page", relayURL)
+			}
+			eventsByRelay[i], rejections[i] = verifyEvents(relayURL, events, authors)
+		}(i, relayURL)
+	}
+	wg.Wait()
+
+	return eventsByRelay, results, rejections
+}
+
+// truncation describes how the events returned by a relay may be incomplete
+type truncation struct {
+	seconds   int  // Seconds skipped because a page only held their events
+	pageLimit bool // Pagination stopped at maxRelayPages
 }
 
-// queryRelays queries all configured relays for events
-func (nc *NostrCollector) queryRelays(ctx context.Context, pubkeys []string, targetDate *time.Time) ([]*nostr.Event, error) {
-	var allEvents []*nostr.Event
+// queryRelay connects to a single relay and runs every filter, closing the
+// connection as soon as the queries are done. It also returns how the events
+// may have been truncated, see queryPages.
+func (nc *NostrCollector) queryRelay(ctx context.Context, relayURL string, filters []nostr.Filter) ([]*nostr.Event, truncation, error) {
+	// The relay connection lives as long as the context it was created with,
+	// while dialing and every query page get their own deadlines
+	relayCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
 
-	// Calculate time range (last 7 days)
-	var since time.Time
-	if targetDate != nil {
-		since = targetDate.AddDate(0, 0, -7)
-	} else {
-		since = time.Now().AddDate(0, 0, -7)
+	relay := nostr.NewRelay(relayCtx, relayURL)
+
+	connectCtx, cancelConnect := context.WithTimeout(relayCtx, nc.options.ConnectTimeout)
+	err := relay.Connect(connectCtx)
+	cancelConnect()
+	if err != nil {
+		return nil, truncation{}, fmt.Errorf("failed to connect: %w", err)
 	}
-	until := time.Now()
+	defer relay.Close()
 
-	// Convert to nostr timestamps
-	sinceTimestamp := nostr.Timestamp(since.Unix())
-	untilTimestamp := nostr.Timestamp(until.Unix())
+	seen := make(map[string]bool)
+	var events []*nostr.Event
+	var truncated truncation
+	for _, filter := range filters {
+		page, pageTruncated, err := nc.queryPages(relayCtx, relay, filter, seen)
+		if err != nil {
+			return nil, truncation{}, err
+		}
+		events = append(events, page...)
+		truncated.seconds += pageTruncated.seconds
+		truncated.pageLimit = truncated.pageLimit || pageTruncated.pageLimit
+	}
 
-	// Query each relay
-	for _, relayURL := range nc.relays {
-		log.Printf("Querying relay: %s", relayURL)
+	return events, truncated, nil
+}
 
-		relay, err := nostr.RelayConnect(ctx, relayURL)
+// queryPages fetches all events matching the filter, moving the until cursor
+// back to the oldest event received until a page brings no new events or the
+// cursor passes since. Relays may cap pages below the requested limit, so a
+// short page does not mean the end. Events already in seen are skipped, since
+// the cursor timestamp is inclusive.
+//
+// A page holding only events of the cursor second cannot be split further, so
+// the cursor skips past that second and events of it the relay did not return
+// are missed. The number of such seconds is returned as truncated, together
+// with whether pagination stopped at maxRelayPages before reaching since.
+func (nc *NostrCollector) queryPages(ctx context.Context, relay *nostr.Relay, filter nostr.Filter, seen map[string]bool) ([]*nostr.Event, truncation, error) {
+	filter.Limit = nc.options.PageLimit
+	until := *filter.Until
+
+	var events []*nostr.Event
+	var truncated truncation
+	for page := 0; page < maxRelayPages; page++ {
+		filter.Until = &until
+
+		queryCtx, cancel := context.WithTimeout(ctx, nc.options.QueryTimeout)
+		results, err := relay.QuerySync(queryCtx, filter)
+		cancel()
 		if err != nil {
-			log.Printf("Failed to connect to relay %s: %v", relayURL, err)
-			continue
+			return nil, truncation{}, fmt.Errorf("failed to query page %d: %w", page+1, err)
 		}
-		defer relay.Close()
 
-		// Create filter for the pubkeys and time range
-		filter := nostr.Filter{
-			Authors: pubkeys,
-			Since:   &sinceTimestamp,
-			Until:   &untilTimestamp,
-			Kinds:   []int{0, 1, 4, 30023, 397, 30398, 30399}, // Profile metadata, notes, encrypted DMs, long-form content, app-specific data, community posts, community post replies
+		oldest := until
+		added := 0
+		for _, event := range results {
+			if event.CreatedAt < oldest {
+				oldest = event.CreatedAt
+			}
+			if seen[event.ID] {
+				continue
+			}
+			seen[event.ID] = true
+			events = append(events, event)
+			added++
 		}
 
-		// Query the relay
-		events, err := relay.QuerySync(ctx, filter)
-		if err != nil {
-			log.Printf("Failed to query relay %s: %v", relayURL, err)
-			continue
+		if added == 0 {
+			return events, truncated, nil
+		}
+
+		if oldest == until {
+			log.Printf("Relay %s returned a page of events all at %d, skipping to the previous second", relay.URL, until)
+			truncated.seconds++
+			oldest--
+		}
+		if oldest < *filter.Since {
+			return events, truncated, nil
 		}
+		until = oldest
+	}
+
+	log.Printf("Stopped paginating relay %s after %d pages", relay.URL, maxRelayPages)
+	truncated.pageLimit = true
+	return events, truncated, nil
+}
 
-		log.Printf("Found %d events from relay %s", len(events), relayURL)
-		allEvents = append(allEvents, events...)
+// batchStrings splits values into batches of at most size items
+func batchStrings(values []string, size int) [][]string {
+	if size <= 0 || len(values) <= size {
+		return [][]string{values}
 	}
 
-	log.Printf("Total events found across all relays: %d", len(allEvents))
-	return allEvents, nil
+	var batches [][]string
+	for start := 0; start < len(values); start += size {
+		end := start + size
+		if end > len(values) {
+			end = len(values)
+		}
+		batches = append(batches, values[start:end])
+	}
+	return batches
 }
 
 // processEvents processes the events to extract metrics
-func (nc *NostrCollector) processEvents(events []*nostr.Event, targetDate *time.Time) (int, []models.DailyNotes) {
+func (nc *NostrCollector) processEvents(events []*nostr.Event, window Window) (int, []models.DailyNotes) {
 	// Track active posters (unique authors)
 	activeAuthors := make(map[string]bool)
 
-	// Track notes by kind and day
+	// Track notes by kind and period
 	notesByDay := make(map[string]map[string]int)
 
-	// Use target date or current date for base
-	var baseDate time.Time
-	if targetDate != nil {
-		baseDate = *targetDate
-	} else {
-		baseDate = time.Now()
-	}
-
-	// Initialize notesByDay for the last 7 days
-	for i := 6; i >= 0; i-- {
-		date := baseDate.AddDate(0, 0, -i).Format("2006-01-02")
-		notesByDay[date] = map[string]int{
-			"0":     0, // Profile metadata
-			"1":     0, // Notes
-			"4":     0, // Encrypted DMs
-			"30023": 0, // Long-form content
-			"397":   0, // App-specific data
-			"30398": 0, // Community post
-			"30399": 0, // Community post reply
+	// Initialize notesByDay for every period in the window with the reported kinds
+	kinds := reportKinds(nc.options.Kinds)
+	periods := window.Periods()
+	for _, date := range periods {
+		notesByDay[date] = make(map[string]int, len(kinds))
+		for _, kind := range kinds {
+			notesByDay[date][kind] = 0
 		}
 	}
 
@@ -222,13 +411,13 @@ func (nc *NostrCollector) processEvents(events []*nostr.Event, targetDate *time.
 		// Track active authors
 		activeAuthors[event.PubKey] = true
 
-		// Get event date
-		eventDate := time.Unix(int64(event.CreatedAt), 0).Format("2006-01-02")
+		// Get event period
+		eventDate := window.Key(time.Unix(int64(event.CreatedAt), 0))
 
 		// Check if this date is within our range
 		if dayData, exists := notesByDay[eventDate]; exists {
 			kindStr := fmt.Sprintf("%d", event.Kind)
-			if kindStr == "0" || kindStr == "1" || kindStr == "4" || kindStr == "30023" || kindStr == "397" || kindStr == "30398" || kindStr == "30399" {
+			if _, reported := dayData[kindStr]; reported {
 				dayData[kindStr]++
 			}
 		}
@@ -236,8 +425,7 @@ func (nc *NostrCollector) processEvents(events []*nostr.Event, targetDate *time.
 
 	// Convert to DailyNotes format
 	var results []models.DailyNotes
-	for i := 6; i >= 0; i-- {
-		date := baseDate.AddDate(0, 0, -i).Format("2006-01-02")
+	for _, date := range periods {
 		if dayData, exists := notesByDay[date]; exists {
 			results = append(results, models.DailyNotes{
 				Date:  date,
@@ -249,7 +437,6 @@ func (nc *NostrCollector) processEvents(events []*nostr.Event, targetDate *time.
 	return len(activeAuthors), results
 }
 
-
 // aggregateNotesByKind aggregates events by kind and day (placeholder for future implementation)
 func (nc *NostrCollector) aggregateNotesByKind(events map[string]interface{}) []models.DailyNotes {
 	// This will be implemented when nostr library dependencies are resolved
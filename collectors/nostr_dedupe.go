@@ -0,0 +1,101 @@
+package collectors
+
+import (
+	"fmt"
+
+	"github.com/nbd-wtf/go-nostr"
+	"kpi.trustroots.org/models"
+)
+
+// dedupeEvents merges the events received from each relay into a single list
+// without duplicates. Events mirrored on several relays are counted once by ID,
+// and replaceable and addressable events are collapsed to their latest version
+// per pubkey+kind (and d-tag). It also reports how much the relays overlap.
+func dedupeEvents(relays []string, eventsByRelay [][]*nostr.Event) ([]*nostr.Event, models.RelayOverlap) {
+	overlap := models.RelayOverlap{}
+
+	// Deduplicate by event ID, remembering which relays had each event
+	byID := make(map[string]*nostr.Event)
+	seenOn := make(map[string]map[int]bool)
+	var order []string
+	for i, events := range eventsByRelay {
+		for _, event := range events {
+			overlap.Received++
+			if _, exists := byID[event.ID]; !exists {
+				byID[event.ID] = event
+				seenOn[event.ID] = make(map[int]bool)
+				order = append(order, event.ID)
+			} else if !seenOn[event.ID][i] {
+				overlap.Duplicates++
+			}
+			seenOn[event.ID][i] = true
+		}
+	}
+	overlap.Unique = len(order)
+
+	// Per-relay overlap: events only this relay had vs events mirrored elsewhere
+	overlap.PerRelay = make([]models.RelayOverlapEntry, len(relays))
+	for i, relayURL := range relays {
+		overlap.PerRelay[i].URL = relayURL
+	}
+	for _, id := range order {
+		for i := range seenOn[id] {
+			overlap.PerRelay[i].Events++
+			if len(seenOn[id]) == 1 {
+				overlap.PerRelay[i].Exclusive++
+			} else {
+				overlap.PerRelay[i].Shared++
+			}
+		}
+	}
+
+	// Collapse replaceable and addressable events to their latest version
+	latest := make(map[string]*nostr.Event)
+	var result []*nostr.Event
+	for _, id := range order {
+		event := byID[id]
+		key := replaceableKey(event)
+		if key == "" {
+			result = append(result, event)
+			continue
+		}
+
+		current, exists := latest[key]
+		if !exists {
+			latest[key] = event
+			continue
+		}
+		overlap.Collapsed++
+		if newerEvent(event, current) {
+			latest[key] = event
+		}
+	}
+	for _, event := range latest {
+		result = append(result, event)
+	}
+
+	overlap.Counted = len(result)
+	return result, overlap
+}
+
+// replaceableKey returns the key identifying all versions of a replaceable or
+// addressable event, or an empty string for regular events
+func replaceableKey(event *nostr.Event) string {
+	switch {
+	case nostr.IsReplaceableKind(event.Kind):
+		return fmt.Sprintf("%s:%d", event.PubKey, event.Kind)
+	case nostr.IsAddressableKind(event.Kind):
+		return fmt.Sprintf("%s:%d:%s", event.PubKey, event.Kind, event.Tags.GetD())
+	default:
+		return ""
+	}
+}
+
+// newerEvent reports whether a replaces b. On equal timestamps the lowest ID
+// wins, as specified by NIP-01.
+func newerEvent(a, b *nostr.Event) bool {
+	if a.CreatedAt != b.CreatedAt {
+		return a.CreatedAt > b.CreatedAt
+	}
+	return a.ID < b.ID
+}
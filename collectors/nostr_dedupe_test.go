@@ -0,0 +1,121 @@
+package collectors
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/nbd-wtf/go-nostr"
+	"kpi.trustroots.org/models"
+)
+
+// testEvent creates an event with the given d-tag, if any
+func testEvent(id, pubkey string, kind int, createdAt nostr.Timestamp, d string) *nostr.Event {
+	event := &nostr.Event{ID: id, PubKey: pubkey, Kind: kind, CreatedAt: createdAt}
+	if d != "" {
+		event.Tags = nostr.Tags{{"d", d}}
+	}
+	return event
+}
+
+func TestDedupeEvents(t *testing.T) {
+	note := testEvent("note", "alice", 1, 100, "")
+	other := testEvent("other", "alice", 1, 200, "")
+	profileOld := testEvent("profile-old", "alice", 0, 100, "")
+	profileNew := testEvent("profile-new", "alice", 0, 200, "")
+	tieA := testEvent("a", "alice", 0, 100, "")
+	tieB := testEvent("b", "alice", 0, 100, "")
+	articleX := testEvent("article-x", "alice", 30023, 100, "x")
+	articleY := testEvent("article-y", "alice", 30023, 100, "y")
+	articleX2 := testEvent("article-x2", "alice", 30023, 200, "x")
+
+	tests := []struct {
+		name    string
+		events  [][]*nostr.Event
+		want    []string
+		overlap models.RelayOverlap
+		// perRelay holds the events, exclusive and shared counts of each relay
+		perRelay [][3]int
+	}{
+		{
+			name:     "event mirrored on two relays",
+			events:   [][]*nostr.Event{{note}, {note, other}},
+			want:     []string{"note", "other"},
+			overlap:  models.RelayOverlap{Received: 3, Unique: 2, Duplicates: 1, Counted: 2},
+			perRelay: [][3]int{{1, 0, 1}, {2, 1, 1}},
+		},
+		{
+			name:     "replaceable event collapses to the newest version",
+			events:   [][]*nostr.Event{{profileNew}, {profileOld}},
+			want:     []string{"profile-new"},
+			overlap:  models.RelayOverlap{Received: 2, Unique: 2, Collapsed: 1, Counted: 1},
+			perRelay: [][3]int{{1, 1, 0}, {1, 1, 0}},
+		},
+		{
+			name:     "newest version wins when received first",
+			events:   [][]*nostr.Event{{profileOld, profileNew}},
+			want:     []string{"profile-new"},
+			overlap:  models.RelayOverlap{Received: 2, Unique: 2, Collapsed: 1, Counted: 1},
+			perRelay: [][3]int{{2, 2, 0}},
+		},
+		{
+			name:     "lowest ID wins on equal timestamps",
+			events:   [][]*nostr.Event{{tieB}, {tieA}},
+			want:     []string{"a"},
+			overlap:  models.RelayOverlap{Received: 2, Unique: 2, Collapsed: 1, Counted: 1},
+			perRelay: [][3]int{{1, 1, 0}, {1, 1, 0}},
+		},
+		{
+			name:     "lowest ID wins regardless of order",
+			events:   [][]*nostr.Event{{tieA, tieB}},
+			want:     []string{"a"},
+			overlap:  models.RelayOverlap{Received: 2, Unique: 2, Collapsed: 1, Counted: 1},
+			perRelay: [][3]int{{2, 2, 0}},
+		},
+		{
+			name:     "addressable events with different d-tags stay separate",
+			events:   [][]*nostr.Event{{articleX, articleY}, {articleX2}},
+			want:     []string{"article-x2", "article-y"},
+			overlap:  models.RelayOverlap{Received: 3, Unique: 3, Collapsed: 1, Counted: 2},
+			perRelay: [][3]int{{2, 2, 0}, {1, 1, 0}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			relays := make([]string, len(tt.events))
+			for i := range relays {
+				relays[i] = fmt.Sprintf("wss://relay%d.example", i)
+			}
+
+			events, overlap := dedupeEvents(relays, tt.events)
+
+			ids := make([]string, 0, len(events))
+			for _, event := range events {
+				ids = append(ids, event.ID)
+			}
+			sort.Strings(ids)
+			if strings.Join(ids, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("events = %v, want %v", ids, tt.want)
+			}
+
+			if overlap.Received != tt.overlap.Received || overlap.Unique != tt.overlap.Unique ||
+				overlap.Duplicates != tt.overlap.Duplicates || overlap.Collapsed != tt.overlap.Collapsed ||
+				overlap.Counted != tt.overlap.Counted {
+				t.Errorf("overlap = %+v, want %+v", overlap, tt.overlap)
+			}
+
+			if len(overlap.PerRelay) != len(tt.perRelay) {
+				t.Fatalf("got %d relay entries, want %d", len(overlap.PerRelay), len(tt.perRelay))
+			}
+			for i, want := range tt.perRelay {
+				entry := overlap.PerRelay[i]
+				got := [3]int{entry.Events, entry.Exclusive, entry.Shared}
+				if entry.URL != relays[i] || got != want {
+					t.Errorf("relay %d = %+v, want %s with events, exclusive, shared %v", i, entry, relays[i], want)
+				}
+			}
+		})
+	}
+}
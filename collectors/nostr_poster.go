@@ -8,6 +8,7 @@ import (
 
 	"github.com/nbd-wtf/go-nostr"
 	"github.com/nbd-wtf/go-nostr/nip19"
+	"kpi.trustroots.org/metrics"
 	"kpi.trustroots.org/models"
 )
 
@@ -26,7 +27,7 @@ func NewNostrPoster(relays []string, nsec string) *NostrPoster {
 }
 
 // PostStats posts daily stats to Nostr
-func (np *NostrPoster) PostStats(data *models.KPIData) error {
+func (np *NostrPoster) PostStats(ctx context.Context, data *models.KPIData) error {
 	if np.nsec == "" {
 		log.Println("NSEC_STATS not configured, skipping Nostr post")
 		return nil
@@ -69,7 +70,7 @@ func (np *NostrPoster) PostStats(data *models.KPIData) error {
 	}
 
 	// Post to all relays
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
 	defer cancel()
 
 	successCount := 0
@@ -77,12 +78,14 @@ func (np *NostrPoster) PostStats(data *models.KPIData) error {
 		relay, err := nostr.RelayConnect(ctx, relayURL)
 		if err != nil {
 			log.Printf("Failed to connect to relay %s: %v", relayURL, err)
+			metrics.ObserveRelay(relayURL, "publish", err)
 			continue
 		}
 
 		// Publish the event
 		err = relay.Publish(ctx, *event)
 		relay.Close()
+		metrics.ObserveRelay(relayURL, "publish", err)
 
 		if err != nil {
 			log.Printf("Failed to publish to relay %s: %v", relayURL, err)
@@ -102,7 +105,7 @@ func (np *NostrPoster) PostStats(data *models.KPIData) error {
 
 // formatStatsMessage formats the stats data into a readable message
 func (np *NostrPoster) formatStatsMessage(data *models.KPIData) string {
-	// Get yesterday's date
+	// Get yesterday's date in the reporting timezone, which Generated is in
 	yesterday := data.Generated.AddDate(0, 0, -1).Format("2006-01-02")
 
 	// Calculate yesterday's message count
@@ -0,0 +1,32 @@
+package collectors
+
+import (
+	"github.com/nbd-wtf/go-nostr"
+	"kpi.trustroots.org/models"
+)
+
+// verifyEvents keeps only events with a correct ID hash, a valid Schnorr
+// signature and an author from the queried pubkeys, so a misbehaving relay
+// cannot inflate the numbers. Rejected events are counted by reason.
+func verifyEvents(relayURL string, events []*nostr.Event, authors map[string]bool) ([]*nostr.Event, models.RelayRejections) {
+	rejections := models.RelayRejections{URL: relayURL}
+
+	valid := make([]*nostr.Event, 0, len(events))
+	for _, event := range events {
+		if !authors[event.PubKey] {
+			rejections.UnknownAuthor++
+			continue
+		}
+		if !event.CheckID() {
+			rejections.InvalidID++
+			continue
+		}
+		if ok, err := event.CheckSignature(); err != nil || !ok {
+			rejections.InvalidSignature++
+			continue
+		}
+		valid = append(valid, event)
+	}
+
+	return valid, rejections
+}
@@ -0,0 +1,171 @@
+package collectors
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"kpi.trustroots.org/models"
+)
+
+// Collector is a source of KPI metrics
+type Collector interface {
+	// Name returns the name the collector is registered under
+	Name() string
+
+	// Collect gathers the metrics for the window and returns a function
+	// that writes them into the KPI output. It must stop when ctx is done.
+	Collect(ctx context.Context, window Window) (func(*models.KPIData), error)
+}
+
+// Dependencies are the shared resources collectors are built from
+type Dependencies struct {
+	Mongo    *MongoCollector
+	Relays   []string
+	Nostr    NostrOptions
+	Geo      GeoOptions
+	Cohorts  CohortOptions
+	Contacts ContactOptions
+}
+
+// Factory creates a collector from the shared dependencies
+type Factory func(deps Dependencies) (Collector, error)
+
+var (
+	registry      = make(map[string]Factory)
+	registryOrder []string
+	optional      = make(map[string]bool)
+	snapshots     = make(map[string]bool)
+	fixed         = make(map[string]bool)
+)
+
+// Register makes a collector available under the given name.
+// Collectors run in the order they were registered.
+func Register(name string, factory Factory) {
+	if _, exists := registry[name]; exists {
+		panic(fmt.Sprintf("collector %s registered twice", name))
+	}
+	registry[name] = factory
+	registryOrder = append(registryOrder, name)
+}
+
+// RegisterOptional makes a collector available that only runs when it is
+// explicitly enabled, for example because it is expensive
+func RegisterOptional(name string, factory Factory) {
+	Register(name, factory)
+	optional[name] = true
+}
+
+// markSnapshot flags a registered collector whose output is not broken down by
+// day, such as a retention matrix or top tables
+func markSnapshot(name string) {
+	snapshots[name] = true
+}
+
+// Daily returns the collectors whose output is broken down by day, skipping
+// snapshot collectors whose output a daily history would throw away
+func Daily(list []Collector) []Collector {
+	var daily []Collector
+	for _, collector := range list {
+		if !snapshots[collector.Name()] {
+			daily = append(daily, collector)
+		}
+	}
+	return daily
+}
+
+// markFixedGranularity flags a registered collector whose output does not
+// depend on the granularity of the window, such as cohorts with their own
+func markFixedGranularity(name string) {
+	fixed[name] = true
+}
+
+// Rollups returns the collectors whose output depends on the granularity,
+// skipping those that would only repeat the daily output in a rollup
+func Rollups(list []Collector) []Collector {
+	var rollups []Collector
+	for _, collector := range list {
+		if !fixed[collector.Name()] {
+			rollups = append(rollups, collector)
+		}
+	}
+	return rollups
+}
+
+// Registered returns the names of all registered collectors
+func Registered() []string {
+	names := make([]string, len(registryOrder))
+	copy(names, registryOrder)
+	return names
+}
+
+// Build creates the enabled collectors. If enabled is empty, every registered
+// collector except the optional ones is enabled. Collectors listed in extra are
+// enabled in addition, and collectors listed in disabled are always skipped.
+func Build(deps Dependencies, enabled, extra, disabled []string) ([]Collector, error) {
+	enabledSet, err := nameSet(enabled)
+	if err != nil {
+		return nil, err
+	}
+	extraSet, err := nameSet(extra)
+	if err != nil {
+		return nil, err
+	}
+	disabledSet, err := nameSet(disabled)
+	if err != nil {
+		return nil, err
+	}
+
+	var collectors []Collector
+	for _, name := range registryOrder {
+		if disabledSet[name] {
+			continue
+		}
+		if !enabledSet[name] && !extraSet[name] && (len(enabledSet) > 0 || optional[name]) {
+			continue
+		}
+
+		collector, err := registry[name](deps)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create collector %s: %w", name, err)
+		}
+		collectors = append(collectors, collector)
+	}
+
+	return collectors, nil
+}
+
+// nameSet converts a list of collector names to a set, rejecting unknown names
+func nameSet(names []string) (map[string]bool, error) {
+	set := make(map[string]bool)
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if _, exists := registry[name]; !exists {
+			known := Registered()
+			sort.Strings(known)
+			return nil, fmt.Errorf("unknown collector '%s' (available: %s)", name, strings.Join(known, ", "))
+		}
+		set[name] = true
+	}
+	return set, nil
+}
+
+// collectorFunc adapts a function to the Collector interface
+type collectorFunc struct {
+	name    string
+	collect func(ctx context.Context, window Window) (func(*models.KPIData), error)
+}
+
+// Name returns the collector name
+func (cf collectorFunc) Name() string {
+	return cf.name
+}
+
+// Collect runs the collector function
+func (cf collectorFunc) Collect(ctx context.Context, window Window) (func(*models.KPIData), error) {
+	return cf.collect(ctx, window)
+}
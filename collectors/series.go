@@ -0,0 +1,17 @@
+package collectors
+
+// buildSeries returns exactly one entry per period of the window, in order, so
+// every series has the same length. Periods without a row get the value
+// returned by empty, and rows outside the window are dropped.
+func buildSeries[T any](window Window, rows map[string]T, empty func(date string) T) []T {
+	periods := window.Periods()
+	series := make([]T, 0, len(periods))
+	for _, date := range periods {
+		if row, exists := rows[date]; exists {
+			series = append(series, row)
+		} else {
+			series = append(series, empty(date))
+		}
+	}
+	return series
+}
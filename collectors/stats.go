@@ -0,0 +1,31 @@
+package collectors
+
+import (
+	"math"
+	"sort"
+)
+
+// percentile returns the p-th percentile (0-100) of the values using the
+// nearest-rank method, or nil if there are no values. values is sorted in place.
+func percentile(values []int64, p float64) *int64 {
+	if len(values) == 0 {
+		return nil
+	}
+	sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+
+	rank := int(math.Ceil(p / 100 * float64(len(values))))
+	if rank < 1 {
+		rank = 1
+	}
+	value := values[rank-1]
+	return &value
+}
+
+// ratio returns part/total, or nil if total is zero
+func ratio(part, total int) *float64 {
+	if total == 0 {
+		return nil
+	}
+	value := float64(part) / float64(total)
+	return &value
+}
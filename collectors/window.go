@@ -0,0 +1,164 @@
+package collectors
+
+import (
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Granularity is the period size metrics are grouped by
+type Granularity string
+
+const (
+	GranularityDay   Granularity = "day"
+	GranularityWeek  Granularity = "week"
+	GranularityMonth Granularity = "month"
+)
+
+// ParseGranularity parses a granularity name (day, week or month)
+func ParseGranularity(value string) (Granularity, error) {
+	switch g := Granularity(value); g {
+	case GranularityDay, GranularityWeek, GranularityMonth:
+		return g, nil
+	}
+	return "", fmt.Errorf("unknown granularity '%s' (use day, week or month)", value)
+}
+
+// ParseTimezone loads the reporting timezone from an IANA name such as
+// Europe/Berlin. The server's local zone is rejected, since MongoDB needs an
+// explicit name to compute the same days.
+func ParseTimezone(name string) (*time.Location, error) {
+	if name == "" || name == "Local" {
+		return nil, fmt.Errorf("timezone must be an IANA name such as UTC or Europe/Berlin")
+	}
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("unknown timezone '%s': %w", name, err)
+	}
+	return location, nil
+}
+
+// Window is the time range metrics are collected for and how they are grouped.
+// Days start at midnight in Location (UTC if nil).
+type Window struct {
+	Since       time.Time
+	Until       time.Time
+	Granularity Granularity
+	Location    *time.Location
+}
+
+// NewWindow creates a window covering lookbackDays days before the target date
+// (or today) up to the end of that day in the given location. For week and month
+// granularity the start is moved back to the beginning of its period so the first
+// period is complete.
+func NewWindow(targetDate *time.Time, lookbackDays int, granularity Granularity, location *time.Location) Window {
+	w := Window{Granularity: granularity, Location: location}
+
+	// Use target date or current date
+	var baseDate time.Time
+	if targetDate != nil {
+		baseDate = targetDate.In(w.location())
+	} else {
+		baseDate = time.Now().In(w.location())
+	}
+
+	// Days are built from calendar dates rather than 24 hour steps, so days
+	// with a DST change are 23 or 25 hours long
+	w.Since = w.periodStart(w.dayStart(baseDate, -lookbackDays))
+	w.Until = w.dayStart(baseDate, 1)
+
+	return w
+}
+
+// Past reports whether the window has already ended, as when backfilling.
+// Snapshots of the current state do not describe such a window.
+func (w Window) Past() bool {
+	return !time.Now().Before(w.Until)
+}
+
+// Key returns the period key for a point in time
+func (w Window) Key(t time.Time) string {
+	t = t.In(w.location())
+	switch w.Granularity {
+	case GranularityWeek:
+		year, week := t.ISOWeek()
+		return fmt.Sprintf("%d-W%02d", year, week)
+	case GranularityMonth:
+		return t.Format("2006-01")
+	default:
+		return t.Format("2006-01-02")
+	}
+}
+
+// Periods returns the keys of all periods in the window, in order
+func (w Window) Periods() []string {
+	var periods []string
+	for day := w.Since.In(w.location()); day.Before(w.Until); day = day.AddDate(0, 0, 1) {
+		key := w.Key(day)
+		if len(periods) == 0 || periods[len(periods)-1] != key {
+			periods = append(periods, key)
+		}
+	}
+	return periods
+}
+
+// MatchRange returns a Mongo range filter selecting dates inside the window
+func (w Window) MatchRange() bson.M {
+	return bson.M{
+		"$gte": w.Since,
+		"$lt":  w.Until,
+	}
+}
+
+// DateToString returns a Mongo expression converting a date field to its period key.
+// The formats and timezone match Key.
+func (w Window) DateToString(field string) bson.M {
+	return bson.M{
+		"$dateToString": bson.M{
+			"format":   w.mongoFormat(),
+			"date":     field,
+			"timezone": w.location().String(),
+		},
+	}
+}
+
+// mongoFormat returns the $dateToString format for the granularity
+func (w Window) mongoFormat() string {
+	switch w.Granularity {
+	case GranularityWeek:
+		return "%G-W%V"
+	case GranularityMonth:
+		return "%Y-%m"
+	default:
+		return "%Y-%m-%d"
+	}
+}
+
+// periodStart returns the start of the period containing the given day
+func (w Window) periodStart(day time.Time) time.Time {
+	switch w.Granularity {
+	case GranularityWeek:
+		// ISO weeks start on Monday
+		offset := (int(day.Weekday()) + 6) % 7
+		return w.dayStart(day, -offset)
+	case GranularityMonth:
+		return time.Date(day.Year(), day.Month(), 1, 0, 0, 0, 0, w.location())
+	default:
+		return day
+	}
+}
+
+// dayStart returns midnight of the day the given number of days after t
+func (w Window) dayStart(t time.Time, days int) time.Time {
+	t = t.In(w.location())
+	return time.Date(t.Year(), t.Month(), t.Day()+days, 0, 0, 0, 0, w.location())
+}
+
+// location returns the reporting timezone, defaulting to UTC
+func (w Window) location() *time.Location {
+	if w.Location == nil {
+		return time.UTC
+	}
+	return w.Location
+}
@@ -0,0 +1,192 @@
+package collectors
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// berlin loads a timezone with DST changes. In 2026 clocks go forward on
+// March 29 and back on October 25.
+func berlin(t *testing.T) *time.Location {
+	t.Helper()
+	location, err := ParseTimezone("Europe/Berlin")
+	if err != nil {
+		t.Fatalf("failed to load timezone: %v", err)
+	}
+	return location
+}
+
+// parseDay returns midnight of a YYYY-MM-DD date in location
+func parseDay(t *testing.T, date string, location *time.Location) time.Time {
+	t.Helper()
+	day, err := time.ParseInLocation("2006-01-02", date, location)
+	if err != nil {
+		t.Fatalf("invalid date %s: %v", date, err)
+	}
+	return day
+}
+
+// dateToString evaluates a $dateToString expression for a point in time the
+// way MongoDB does, for the formats used by Window
+func dateToString(t *testing.T, expression bson.M, instant time.Time) string {
+	t.Helper()
+	spec, ok := expression["$dateToString"].(bson.M)
+	if !ok {
+		t.Fatalf("not a $dateToString expression: %v", expression)
+	}
+
+	location, err := time.LoadLocation(spec["timezone"].(string))
+	if err != nil {
+		t.Fatalf("invalid timezone in expression: %v", err)
+	}
+	layout := strings.NewReplacer("%Y", "2006", "%m", "01", "%d", "02").Replace(spec["format"].(string))
+	return instant.In(location).Format(layout)
+}
+
+func TestNewWindowAroundDST(t *testing.T) {
+	location := berlin(t)
+
+	tests := []struct {
+		name        string
+		date        string
+		lookback    int
+		granularity Granularity
+		hours       float64
+		periods     []string
+	}{
+		{
+			name:        "spring forward day",
+			date:        "2026-03-29",
+			granularity: GranularityDay,
+			hours:       23,
+			periods:     []string{"2026-03-29"},
+		},
+		{
+			name:        "fall back day",
+			date:        "2026-10-25",
+			granularity: GranularityDay,
+			hours:       25,
+			periods:     []string{"2026-10-25"},
+		},
+		{
+			name:        "regular day",
+			date:        "2026-06-15",
+			granularity: GranularityDay,
+			hours:       24,
+			periods:     []string{"2026-06-15"},
+		},
+		{
+			name:        "lookback across spring forward",
+			date:        "2026-03-30",
+			lookback:    2,
+			granularity: GranularityDay,
+			hours:       3*24 - 1,
+			periods:     []string{"2026-03-28", "2026-03-29", "2026-03-30"},
+		},
+		{
+			name:        "lookback across fall back",
+			date:        "2026-10-26",
+			lookback:    2,
+			granularity: GranularityDay,
+			hours:       3*24 + 1,
+			periods:     []string{"2026-10-24", "2026-10-25", "2026-10-26"},
+		},
+		{
+			name:        "week ending on spring forward",
+			date:        "2026-03-29",
+			granularity: GranularityWeek,
+			hours:       7*24 - 1,
+			periods:     []string{"2026-W13"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target := parseDay(t, tt.date, location).Add(12 * time.Hour)
+			window := NewWindow(&target, tt.lookback, tt.granularity, location)
+
+			if hours := window.Until.Sub(window.Since).Hours(); hours != tt.hours {
+				t.Errorf("window is %v hours long, want %v", hours, tt.hours)
+			}
+
+			periods := window.Periods()
+			if strings.Join(periods, ",") != strings.Join(tt.periods, ",") {
+				t.Errorf("Periods() = %v, want %v", periods, tt.periods)
+			}
+		})
+	}
+}
+
+func TestKeyAroundDST(t *testing.T) {
+	location := berlin(t)
+	window := Window{Granularity: GranularityDay, Location: location}
+
+	tests := []struct {
+		name    string
+		instant time.Time
+		key     string
+	}{
+		{"start of spring forward day", time.Date(2026, 3, 29, 0, 0, 0, 0, location), "2026-03-29"},
+		{"after the clocks go forward", time.Date(2026, 3, 29, 3, 0, 0, 0, location), "2026-03-29"},
+		{"end of spring forward day", time.Date(2026, 3, 29, 23, 59, 59, 0, location), "2026-03-29"},
+		{"start of fall back day", time.Date(2026, 10, 25, 0, 0, 0, 0, location), "2026-10-25"},
+		{"repeated hour", time.Date(2026, 10, 25, 0, 30, 0, 0, time.UTC), "2026-10-25"},
+		{"end of fall back day", time.Date(2026, 10, 25, 23, 59, 59, 0, location), "2026-10-25"},
+		{"UTC evening of fall back day", time.Date(2026, 10, 25, 23, 30, 0, 0, time.UTC), "2026-10-26"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if key := window.Key(tt.instant); key != tt.key {
+				t.Errorf("Key(%v) = %s, want %s", tt.instant, key, tt.key)
+			}
+		})
+	}
+
+	// Every hour of a DST day must map to that single day
+	for _, date := range []string{"2026-03-29", "2026-10-25"} {
+		day := parseDay(t, date, location)
+		next := window.dayStart(day, 1)
+		for instant := day; instant.Before(next); instant = instant.Add(time.Hour) {
+			if key := window.Key(instant); key != date {
+				t.Errorf("Key(%v) = %s, want %s", instant, key, date)
+			}
+		}
+	}
+}
+
+func TestLateEventOnDSTDayMatchesMongo(t *testing.T) {
+	location := berlin(t)
+	collector := NewNostrCollector(nil, nil, NostrOptions{})
+
+	for _, date := range []string{"2026-03-29", "2026-10-25"} {
+		t.Run(date, func(t *testing.T) {
+			day := parseDay(t, date, location)
+			window := NewWindow(&day, 0, GranularityDay, location)
+			// Built from the wall clock, since adding 23.5 hours to midnight
+			// lands elsewhere on days with a DST change
+			instant := time.Date(day.Year(), day.Month(), day.Day(), 23, 30, 0, 0, location)
+
+			mongoDate := dateToString(t, window.DateToString("$created"), instant)
+			if mongoDate != date {
+				t.Fatalf("$dateToString gives %s, want %s", mongoDate, date)
+			}
+			if key := window.Key(instant); key != mongoDate {
+				t.Errorf("Key() = %s, $dateToString = %s", key, mongoDate)
+			}
+
+			event := &nostr.Event{ID: "event", PubKey: "author", Kind: 1, CreatedAt: nostr.Timestamp(instant.Unix())}
+			_, notes := collector.processEvents([]*nostr.Event{event}, window)
+			if len(notes) != 1 {
+				t.Fatalf("got %d days of notes, want 1", len(notes))
+			}
+			if notes[0].Date != mongoDate || notes[0].Kinds["1"] != 1 {
+				t.Errorf("event counted as %+v, want one kind 1 note on %s", notes[0], mongoDate)
+			}
+		})
+	}
+}
@@ -0,0 +1,162 @@
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"kpi.trustroots.org/models"
+)
+
+// Record is a single day of finalized KPI data as stored on disk
+type Record struct {
+	Date string          `json:"date"`
+	Data *models.KPIData `json:"data"`
+}
+
+// Store keeps per-day KPI records in a JSONL file, one day per line
+type Store struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewStore creates a history store backed by the given file
+func NewStore(path string) *Store {
+	return &Store{path: path}
+}
+
+// Load reads all records from the store, sorted by date.
+// If a date appears more than once, the last line wins.
+func (s *Store) Load() ([]Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	byDate, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	return sortedRecords(byDate), nil
+}
+
+// Save merges the records into the store, replacing existing days with the
+// same date, and rewrites the file atomically
+func (s *Store) Save(records ...Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	byDate, err := s.load()
+	if err != nil {
+		return err
+	}
+	for _, record := range records {
+		byDate[record.Date] = record
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return fmt.Errorf("failed to create history directory: %w", err)
+	}
+
+	// Write to a temporary file first so a crash never leaves a truncated store
+	tmpPath := s.path + ".tmp"
+	file, err := os.Create(tmpPath)
+	if err != nil {
+		return fmt.Errorf("failed to create history file: %w", err)
+	}
+
+	writer := bufio.NewWriter(file)
+	encoder := json.NewEncoder(writer)
+	for _, record := range sortedRecords(byDate) {
+		if err := encoder.Encode(record); err != nil {
+			file.Close()
+			return fmt.Errorf("failed to encode history record %s: %w", record.Date, err)
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write history file: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to close history file: %w", err)
+	}
+
+	if err := os.Rename(tmpPath, s.path); err != nil {
+		return fmt.Errorf("failed to replace history file: %w", err)
+	}
+
+	return nil
+}
+
+// Merged combines all stored days into a single KPI data set with the full
+// time series. Totals are taken from the most recent day.
+func (s *Store) Merged() (*models.KPIData, error) {
+	records, err := s.Load()
+	if err != nil {
+		return nil, err
+	}
+	return Merge(records), nil
+}
+
+// Merge combines day records (sorted by date) into a single KPI data set
+func Merge(records []Record) *models.KPIData {
+	merged := &models.KPIData{}
+	for _, record := range records {
+		if record.Data != nil {
+			merged.AppendDay(record.Data)
+		}
+	}
+	return merged
+}
+
+// load reads the store into a map keyed by date. Callers must hold the lock.
+func (s *Store) load() (map[string]Record, error) {
+	byDate := make(map[string]Record)
+
+	file, err := os.Open(s.path)
+	if os.IsNotExist(err) {
+		return byDate, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open history file: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	// Day records can be larger than the default 64KB token size
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var record Record
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return nil, fmt.Errorf("failed to decode history line %d: %w", line, err)
+		}
+		byDate[record.Date] = record
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read history file: %w", err)
+	}
+
+	return byDate, nil
+}
+
+// sortedRecords returns the records ordered by date
+func sortedRecords(byDate map[string]Record) []Record {
+	records := make([]Record, 0, len(byDate))
+	for _, record := range byDate {
+		records = append(records, record)
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].Date < records[j].Date
+	})
+	return records
+}
@@ -0,0 +1,183 @@
+package history
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"kpi.trustroots.org/models"
+)
+
+// testData returns three days of KPI data with daily series, totals, values
+// only kept with the latest day and values that are not kept at all
+func testData() *models.KPIData {
+	rate := func(value float64) *float64 { return &value }
+	median := func(value int64) *int64 { return &value }
+
+	data := &models.KPIData{Generated: time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)}
+	data.Trustroots.MessagesPerDay = []models.DailyCount{
+		{Date: "2026-10-13", Count: 5},
+		{Date: "2026-10-14", Count: 0},
+		{Date: "2026-10-15", Count: 8},
+	}
+	data.Trustroots.ReviewsPerDay = []models.DailyReview{
+		{Date: "2026-10-13", Positive: 2, Reciprocated: 1, ReciprocatedShare: rate(0.5), MedianReciprocationMs: median(3600000)},
+		{Date: "2026-10-15", Positive: 1, Negative: 1, Unknown: 1},
+	}
+	data.Trustroots.ActiveHosts = 12
+	data.Trustroots.OffersByCountry = []models.CountryCount{{Country: "Germany", Count: 7}, {Country: "France", Count: 5}}
+	data.Trustroots.ContactsPerActiveUser = []models.ContactBucket{{Contacts: "0", Users: 3}, {Contacts: "1", Users: 2}}
+	data.Nostroots.UsersWithNpubs = 42
+	data.Nostroots.ActivePosters = 9
+	data.Nostroots.NotesByKindPerDay = []models.DailyNotes{
+		{Date: "2026-10-14", Kinds: map[string]int{"1": 3, "30023": 1}},
+		{Date: "2026-10-15", Kinds: map[string]int{"1": 4}},
+	}
+	data.Nostroots.Kinds = []models.NostrKind{{Kind: 1, Label: "Note", Category: "social", Query: true, Report: true}}
+	data.Nostroots.Relays = []models.RelayResult{{URL: "wss://relay.example", Events: 8}}
+	return data
+}
+
+// splitDays splits the data into one record per day, keeping the latest
+// values with the last day, the way the service stores finalized days
+func splitDays(data *models.KPIData) []Record {
+	var records []Record
+	for _, date := range data.Dates() {
+		records = append(records, Record{Date: date, Data: data.ForDate(date)})
+	}
+	records[len(records)-1].Data.CopyLatest(data)
+	return records
+}
+
+func TestSplitSaveMergeRoundTrip(t *testing.T) {
+	data := testData()
+	records := splitDays(data)
+
+	dates := make([]string, 0, len(records))
+	for _, record := range records {
+		dates = append(dates, record.Date)
+	}
+	if want := []string{"2026-10-13", "2026-10-14", "2026-10-15"}; !reflect.DeepEqual(dates, want) {
+		t.Fatalf("split into days %v, want %v", dates, want)
+	}
+
+	// Save in two batches, as a backfill does
+	store := NewStore(filepath.Join(t.TempDir(), "history.jsonl"))
+	if err := store.Save(records[:2]...); err != nil {
+		t.Fatalf("Save() failed: %v", err)
+	}
+	if err := store.Save(records[2:]...); err != nil {
+		t.Fatalf("Save() failed: %v", err)
+	}
+
+	loaded, err := store.Load()
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	if len(loaded) != len(records) {
+		t.Fatalf("loaded %d days, want %d", len(loaded), len(records))
+	}
+
+	// Every day keeps only its own rows and the totals, and only the last day
+	// keeps the latest values
+	for i, record := range loaded {
+		day := record.Data
+		for _, row := range day.Trustroots.MessagesPerDay {
+			if row.Date != record.Date {
+				t.Errorf("%s holds a row of %s", record.Date, row.Date)
+			}
+		}
+		if day.Nostroots.UsersWithNpubs != 42 || day.Nostroots.ActivePosters != 9 {
+			t.Errorf("%s totals = %d, %d, want 42, 9", record.Date, day.Nostroots.UsersWithNpubs, day.Nostroots.ActivePosters)
+		}
+		latest := i == len(loaded)-1
+		if hasLatest := day.Trustroots.ActiveHosts != 0 || day.Trustroots.OffersByCountry != nil ||
+			day.Trustroots.ContactsPerActiveUser != nil || day.Nostroots.Kinds != nil; hasLatest != latest {
+			t.Errorf("%s has latest values: %v, want %v", record.Date, hasLatest, latest)
+		}
+		if day.Nostroots.Relays != nil {
+			t.Errorf("%s kept the relays of the current run", record.Date)
+		}
+	}
+
+	merged, err := store.Merged()
+	if err != nil {
+		t.Fatalf("Merged() failed: %v", err)
+	}
+
+	if !merged.Generated.Equal(data.Generated) {
+		t.Errorf("generated = %v, want %v", merged.Generated, data.Generated)
+	}
+	if !reflect.DeepEqual(merged.Trustroots.MessagesPerDay, data.Trustroots.MessagesPerDay) {
+		t.Errorf("messages = %+v, want %+v", merged.Trustroots.MessagesPerDay, data.Trustroots.MessagesPerDay)
+	}
+	if !reflect.DeepEqual(merged.Trustroots.ReviewsPerDay, data.Trustroots.ReviewsPerDay) {
+		t.Errorf("reviews = %+v, want %+v", merged.Trustroots.ReviewsPerDay, data.Trustroots.ReviewsPerDay)
+	}
+	if !reflect.DeepEqual(merged.Nostroots.NotesByKindPerDay, data.Nostroots.NotesByKindPerDay) {
+		t.Errorf("notes = %+v, want %+v", merged.Nostroots.NotesByKindPerDay, data.Nostroots.NotesByKindPerDay)
+	}
+
+	if merged.Nostroots.UsersWithNpubs != 42 || merged.Nostroots.ActivePosters != 9 {
+		t.Errorf("totals = %d, %d, want 42, 9", merged.Nostroots.UsersWithNpubs, merged.Nostroots.ActivePosters)
+	}
+	if merged.Trustroots.ActiveHosts != 12 {
+		t.Errorf("active hosts = %d, want 12", merged.Trustroots.ActiveHosts)
+	}
+	if !reflect.DeepEqual(merged.Trustroots.OffersByCountry, data.Trustroots.OffersByCountry) {
+		t.Errorf("offers by country = %+v, want %+v", merged.Trustroots.OffersByCountry, data.Trustroots.OffersByCountry)
+	}
+	if !reflect.DeepEqual(merged.Trustroots.ContactsPerActiveUser, data.Trustroots.ContactsPerActiveUser) {
+		t.Errorf("contacts per user = %+v, want %+v", merged.Trustroots.ContactsPerActiveUser, data.Trustroots.ContactsPerActiveUser)
+	}
+	if !reflect.DeepEqual(merged.Nostroots.Kinds, data.Nostroots.Kinds) {
+		t.Errorf("kinds = %+v, want %+v", merged.Nostroots.Kinds, data.Nostroots.Kinds)
+	}
+	if merged.Nostroots.Relays != nil {
+		t.Errorf("relays = %+v, want none", merged.Nostroots.Relays)
+	}
+}
+
+func TestMergeKeepsLatestValuesOfLaterDays(t *testing.T) {
+	older := splitDays(testData())
+
+	// A later run stores a new last day with its own latest values, while the
+	// day that held the latest values before is stored again without them
+	newer := testData()
+	newer.Generated = newer.Generated.AddDate(0, 0, 1)
+	newer.Trustroots.MessagesPerDay = []models.DailyCount{{Date: "2026-10-15", Count: 9}, {Date: "2026-10-16", Count: 1}}
+	newer.Trustroots.ReviewsPerDay = nil
+	newer.Nostroots.NotesByKindPerDay = nil
+	newer.Trustroots.ActiveHosts = 13
+	newer.Nostroots.UsersWithNpubs = 43
+
+	store := NewStore(filepath.Join(t.TempDir(), "history.jsonl"))
+	if err := store.Save(older...); err != nil {
+		t.Fatalf("Save() failed: %v", err)
+	}
+	if err := store.Save(splitDays(newer)...); err != nil {
+		t.Fatalf("Save() failed: %v", err)
+	}
+
+	merged, err := store.Merged()
+	if err != nil {
+		t.Fatalf("Merged() failed: %v", err)
+	}
+
+	want := []models.DailyCount{
+		{Date: "2026-10-13", Count: 5},
+		{Date: "2026-10-14", Count: 0},
+		{Date: "2026-10-15", Count: 9},
+		{Date: "2026-10-16", Count: 1},
+	}
+	if !reflect.DeepEqual(merged.Trustroots.MessagesPerDay, want) {
+		t.Errorf("messages = %+v, want %+v", merged.Trustroots.MessagesPerDay, want)
+	}
+	if merged.Trustroots.ActiveHosts != 13 || merged.Nostroots.UsersWithNpubs != 43 {
+		t.Errorf("active hosts, users with npubs = %d, %d, want 13, 43", merged.Trustroots.ActiveHosts, merged.Nostroots.UsersWithNpubs)
+	}
+	if !reflect.DeepEqual(merged.Trustroots.OffersByCountry, newer.Trustroots.OffersByCountry) {
+		t.Errorf("offers by country = %+v, want %+v", merged.Trustroots.OffersByCountry, newer.Trustroots.OffersByCountry)
+	}
+}
@@ -2,32 +2,77 @@ package main
 
 import (
 	"bufio"
+	"context"
 	"flag"
+	"fmt"
 	"log"
+	"net/http"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"syscall"
 	"time"
+	_ "time/tzdata" // Timezone database for images without one
 
 	"kpi.trustroots.org/collectors"
+	"kpi.trustroots.org/history"
+	"kpi.trustroots.org/metrics"
+	"kpi.trustroots.org/models"
+	"kpi.trustroots.org/server"
 )
 
 func main() {
 	// Parse command line flags
 	var once = flag.Bool("once", false, "Run once and exit (don't start the hourly scheduler)")
 	var dateStr = flag.String("date", "", "Run for a specific date (YYYY-MM-DD format)")
+	var backfillFromStr = flag.String("backfill-from", "", "Backfill daily history starting at this date (YYYY-MM-DD format)")
+	var backfillToStr = flag.String("backfill-to", "", "Backfill daily history up to and including this date (YYYY-MM-DD format, default yesterday)")
+	var lookbackDays = flag.Int("lookback-days", 0, "Number of days to look back (overrides LOOKBACK_DAYS)")
+	var granularityStr = flag.String("granularity", "", "Also write week or month rollups next to the daily output (overrides GRANULARITY)")
+	var nostrKindsFile = flag.String("nostr-kinds", "", "JSON file with the Nostr kind catalogue (overrides NOSTR_KINDS_FILE)")
 	flag.Parse()
 
+	// Cancel running collections on SIGINT/SIGTERM for a graceful shutdown
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
 	// Load configuration from environment variables
 	cfg := loadConfig()
 
+	// Command line flags take precedence over configuration
+	if *lookbackDays > 0 {
+		cfg.LookbackDays = *lookbackDays
+	}
+	if *granularityStr != "" {
+		cfg.Granularity = *granularityStr
+	}
+	if *nostrKindsFile != "" {
+		cfg.NostrKindsFile = *nostrKindsFile
+	}
+	granularity, err := collectors.ParseGranularity(cfg.Granularity)
+	if err != nil {
+		log.Fatalf("Invalid granularity: %v", err)
+	}
+	location, err := collectors.ParseTimezone(cfg.ReportTimezone)
+	if err != nil {
+		log.Fatalf("Invalid report timezone: %v", err)
+	}
+	cohortGranularity, err := collectors.ParseGranularity(cfg.CohortGranularity)
+	if err != nil {
+		log.Fatalf("Invalid cohort granularity: %v", err)
+	}
+	nostrKinds, err := collectors.LoadNostrKinds(cfg.NostrKindsFile)
+	if err != nil {
+		log.Fatalf("Failed to load Nostr kinds: %v", err)
+	}
+
 	// Parse date if provided
 	var targetDate *time.Time
 	if *dateStr != "" {
-		parsedDate, err := time.Parse("2006-01-02", *dateStr)
+		parsedDate, err := time.ParseInLocation("2006-01-02", *dateStr, location)
 		if err != nil {
 			log.Fatalf("Invalid date format '%s'. Use YYYY-MM-DD format: %v", *dateStr, err)
 		}
@@ -42,18 +87,111 @@ func main() {
 	}
 	defer mongoCollector.Close()
 
-	// Initialize Nostr collector
-	nostrCollector := collectors.NewNostrCollector(cfg.NostrRelays, mongoCollector.GetDatabase())
-
 	// Initialize Nostr poster
 	nostrPoster := collectors.NewNostrPoster(cfg.NostrRelays, cfg.NsecStats)
 
-	// Initialize aggregator
-	aggregator := collectors.NewAggregator(mongoCollector, nostrCollector)
+	// Initialize the enabled metric collectors
+	deps := collectors.Dependencies{
+		Mongo:  mongoCollector,
+		Relays: cfg.NostrRelays,
+		Nostr: collectors.NostrOptions{
+			ConnectTimeout:  cfg.RelayConnectTimeout,
+			QueryTimeout:    cfg.RelayQueryTimeout,
+			AuthorBatchSize: cfg.NostrAuthorBatchSize,
+			PageLimit:       cfg.NostrPageLimit,
+			Kinds:           nostrKinds,
+		},
+		Geo: collectors.GeoOptions{
+			TopN: cfg.GeoTopN,
+		},
+		Cohorts: collectors.CohortOptions{
+			Granularity: cohortGranularity,
+			Periods:     cfg.CohortPeriods,
+		},
+		Contacts: collectors.ContactOptions{
+			StatePath:      cfg.ContactsStatePath,
+			UpdateInterval: cfg.UpdateInterval,
+		},
+	}
+	enabledCollectors, err := collectors.Build(deps, cfg.EnabledCollectors, cfg.ExtraCollectors, cfg.DisabledCollectors)
+	if err != nil {
+		log.Fatalf("Failed to initialize collectors: %v", err)
+	}
+	log.Printf("Enabled collectors: %s", collectorNames(enabledCollectors))
+
+	// Initialize aggregator. The main output, history and dashboard are always
+	// daily, so week and month rollups are written to their own output.
+	aggregator := collectors.NewAggregator(enabledCollectors, cfg.LookbackDays, collectors.GranularityDay, location, cfg.MaxParallelCollectors)
+
+	// Keep the last written values for collectors that fail on the next run
+	if err := aggregator.LoadPrevious(cfg.OutputPath); err != nil && !os.IsNotExist(err) {
+		log.Printf("Failed to load previous KPI data: %v", err)
+	}
+
+	// Initialize history store
+	store := history.NewStore(cfg.HistoryPath)
+
+	// Run backfill and exit if requested
+	if *backfillFromStr != "" {
+		from, to, err := parseBackfillRange(*backfillFromStr, *backfillToStr, location)
+		if err != nil {
+			log.Fatalf("Invalid backfill range: %v", err)
+		}
+		log.Printf("Backfilling history from %s to %s into %s", from.Format("2006-01-02"), to.Format("2006-01-02"), cfg.HistoryPath)
+
+		// History is kept per day, so collect exactly one day at a time and
+		// leave out collectors without daily values
+		dailyAggregator := collectors.NewAggregator(collectors.Daily(enabledCollectors), 0, collectors.GranularityDay, location, cfg.MaxParallelCollectors)
+		if err := runBackfill(ctx, dailyAggregator, store, cfg.HistoryOutputPath, from, to); err != nil {
+			log.Fatalf("Backfill failed: %v", err)
+		}
+		log.Println("Backfill completed successfully")
+		return
+	}
+
+	// Write week or month rollups in addition to the daily output, leaving out
+	// collectors that would only repeat their daily output
+	var rollup *rollupOutput
+	if granularity != collectors.GranularityDay {
+		rollup = &rollupOutput{
+			aggregator: collectors.NewAggregator(collectors.Rollups(enabledCollectors), cfg.LookbackDays, granularity, location, cfg.MaxParallelCollectors),
+			path:       cfg.RollupOutputPath,
+		}
+		if rollup.path == "" {
+			rollup.path = rollupOutputPath(cfg.OutputPath, granularity)
+		}
+		if err := rollup.aggregator.LoadPrevious(rollup.path); err != nil && !os.IsNotExist(err) {
+			log.Printf("Failed to load previous %s rollup: %v", granularity, err)
+		}
+		log.Printf("Writing %s rollups to %s", granularity, rollup.path)
+	}
+
+	// Start the HTTP API when running as a service
+	var apiServer *server.Server
+	var httpServer *http.Server
+	if cfg.HTTPAddr != "" && !*once {
+		apiServer = server.New(filepath.Dir(cfg.OutputPath), store)
+		httpServer = &http.Server{
+			Addr:              cfg.HTTPAddr,
+			Handler:           apiServer.Handler(),
+			ReadHeaderTimeout: 10 * time.Second,
+		}
+		go func() {
+			log.Printf("HTTP server listening on %s", cfg.HTTPAddr)
+			if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.Fatalf("HTTP server failed: %v", err)
+			}
+		}()
+	}
 
 	// Run collection
 	log.Println("Running KPI collection...")
-	if err := runCollection(aggregator, nostrPoster, cfg.OutputPath, targetDate); err != nil {
+	if err := runCollection(ctx, aggregator, rollup, nostrPoster, store, apiServer, cfg, targetDate); err != nil {
+		if ctx.Err() != nil {
+			log.Println("Collection interrupted, shutting down...")
+			shutdownHTTPServer(httpServer)
+			return
+		}
 		log.Fatalf("Collection failed: %v", err)
 	}
 	log.Println("Collection completed successfully")
@@ -68,10 +206,6 @@ func main() {
 	ticker := time.NewTicker(cfg.UpdateInterval)
 	defer ticker.Stop()
 
-	// Set up signal handling for graceful shutdown
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
-
 	log.Printf("KPI service started. Updating every %v. Output: %s", cfg.UpdateInterval, cfg.OutputPath)
 
 	// Main loop
@@ -79,54 +213,309 @@ func main() {
 		select {
 		case <-ticker.C:
 			log.Println("Running scheduled KPI collection...")
-			if err := runCollection(aggregator, nostrPoster, cfg.OutputPath, nil); err != nil {
+			if err := runCollection(ctx, aggregator, rollup, nostrPoster, store, apiServer, cfg, nil); err != nil {
 				log.Printf("Scheduled collection failed: %v", err)
 			} else {
 				log.Println("Scheduled collection completed successfully")
 			}
 
-		case sig := <-sigChan:
-			log.Printf("Received signal %v, shutting down gracefully...", sig)
+		case <-ctx.Done():
+			log.Println("Received shutdown signal, shutting down gracefully...")
+			shutdownHTTPServer(httpServer)
 			return
 		}
 	}
 }
 
+// shutdownHTTPServer stops the HTTP server, if it is running
+func shutdownHTTPServer(httpServer *http.Server) {
+	if httpServer == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := httpServer.Shutdown(ctx); err != nil {
+		log.Printf("HTTP server shutdown failed: %v", err)
+	}
+}
+
+// rollupOutput collects the weekly or monthly rollups
+type rollupOutput struct {
+	aggregator *collectors.Aggregator
+	path       string
+}
+
+// rollupOutputPath derives the rollup output from the daily output path, for
+// example public/kpi-week.json next to public/kpi.json
+func rollupOutputPath(outputPath string, granularity collectors.Granularity) string {
+	ext := filepath.Ext(outputPath)
+	return strings.TrimSuffix(outputPath, ext) + "-" + string(granularity) + ext
+}
+
 // runCollection performs a single KPI data collection cycle
-func runCollection(aggregator *collectors.Aggregator, nostrPoster *collectors.NostrPoster, outputPath string, targetDate *time.Time) error {
+func runCollection(ctx context.Context, aggregator *collectors.Aggregator, rollup *rollupOutput, nostrPoster *collectors.NostrPoster, store *history.Store, apiServer *server.Server, cfg *Config, targetDate *time.Time) (err error) {
 	start := time.Now()
 
+	// Record the outcome of every run, including failures after collecting
+	defer func() {
+		metrics.ObserveCollection(time.Since(start), err)
+	}()
+
 	// Collect all data
-	data, err := aggregator.CollectAllData(targetDate)
+	data, err := aggregator.CollectAllData(ctx, targetDate)
 	if err != nil {
 		return err
 	}
 
-	// Save to file
-	if err := aggregator.SaveToFile(data, outputPath); err != nil {
+	// Expose latest values to Prometheus
+	metrics.RecordKPIs(data)
+
+	// Save latest data to file
+	if err := aggregator.SaveToFile(data, cfg.OutputPath); err != nil {
 		return err
 	}
 
+	// Export the cohort retention matrix for spreadsheets
+	if cfg.CohortCSVPath != "" && data.Trustroots.Cohorts != nil {
+		if err := collectors.WriteCohortCSV(data.Trustroots.Cohorts, cfg.CohortCSVPath); err != nil {
+			return fmt.Errorf("failed to write cohort CSV: %w", err)
+		}
+	}
+
+	// Store finalized days and write the full history
+	if err := saveHistory(aggregator, store, data, cfg.HistoryOutputPath); err != nil {
+		return err
+	}
+
+	// Serve latest data and the updated history from the HTTP API
+	if apiServer != nil {
+		if err := apiServer.Update(data); err != nil {
+			return err
+		}
+	}
+
 	// Post stats to Nostr
-	if err := nostrPoster.PostStats(data); err != nil {
+	if err := nostrPoster.PostStats(ctx, data); err != nil {
 		log.Printf("Failed to post stats to Nostr: %v", err)
 		// Don't fail the entire collection if Nostr posting fails
 	}
 
-	duration := time.Since(start)
-	log.Printf("Collection completed in %v", duration)
+	// Write the weekly or monthly rollups
+	if rollup != nil {
+		rollupData, err := rollup.aggregator.CollectAllData(ctx, targetDate)
+		if err != nil {
+			return fmt.Errorf("failed to collect rollups: %w", err)
+		}
+		if err := rollup.aggregator.SaveToFile(rollupData, rollup.path); err != nil {
+			return fmt.Errorf("failed to write rollups: %w", err)
+		}
+	}
+
+	log.Printf("Collection completed in %v", time.Since(start))
 
 	return nil
 }
 
+// saveHistory stores every finalized day of the collected data in the history
+// store and writes the merged full history output
+func saveHistory(aggregator *collectors.Aggregator, store *history.Store, data *models.KPIData, historyOutputPath string) error {
+	// Values kept from an earlier run may not cover the finalized days, so
+	// history is only updated when every daily collector succeeded. The days
+	// are stored by the next complete run while they are within the lookback.
+	// Snapshot collectors are not stored per day, so their failures are ignored.
+	if name := failedCollector(data, collectors.Daily(aggregator.Collectors())); name != "" {
+		log.Printf("Skipping history update because the %s collector failed", name)
+		return nil
+	}
+
+	today := time.Now().In(aggregator.Location()).Format("2006-01-02")
+
+	var records []history.Record
+	for _, date := range data.Dates() {
+		// Today is still in progress, so it is not final yet
+		if date >= today {
+			continue
+		}
+		records = append(records, history.Record{Date: date, Data: data.ForDate(date)})
+	}
+	if len(records) > 0 {
+		records[len(records)-1].Data.CopyLatest(data)
+	}
+
+	if err := store.Save(records...); err != nil {
+		return fmt.Errorf("failed to save history: %w", err)
+	}
+
+	return writeHistoryOutput(aggregator, store, historyOutputPath)
+}
+
+// failedCollector returns the name of one of the given collectors that failed
+// in the run the data was collected in, or an empty string if all succeeded
+func failedCollector(data *models.KPIData, list []collectors.Collector) string {
+	var names []string
+	for _, collector := range list {
+		if status, ok := data.Status[collector.Name()]; ok && !status.OK {
+			names = append(names, collector.Name())
+		}
+	}
+	if len(names) == 0 {
+		return ""
+	}
+	sort.Strings(names)
+	return names[0]
+}
+
+// writeHistoryOutput writes the merged history from the store to a JSON file
+func writeHistoryOutput(aggregator *collectors.Aggregator, store *history.Store, historyOutputPath string) error {
+	merged, err := store.Merged()
+	if err != nil {
+		return fmt.Errorf("failed to load history: %w", err)
+	}
+
+	if err := aggregator.SaveToFile(merged, historyOutputPath); err != nil {
+		return fmt.Errorf("failed to write history output: %w", err)
+	}
+
+	return nil
+}
+
+// backfillBatchDays is the number of backfilled days saved to the history store at once
+const backfillBatchDays = 30
+
+// runBackfill collects KPI data for every day in the range and stores each
+// day in the history store, so past trends can be reconstructed. Days are
+// saved in batches, since every save rewrites the whole store. Days on which a
+// collector failed are skipped, so they never replace good stored days, and
+// reported as an error at the end.
+func runBackfill(ctx context.Context, aggregator *collectors.Aggregator, store *history.Store, historyOutputPath string, from, to time.Time) error {
+	start := time.Now()
+	days := 0
+	var skipped []string
+
+	var batch []history.Record
+	saveBatch := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := store.Save(batch...); err != nil {
+			return fmt.Errorf("failed to save history up to %s: %w", batch[len(batch)-1].Date, err)
+		}
+		batch = batch[:0]
+		return nil
+	}
+
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		date := day.Format("2006-01-02")
+
+		data, err := aggregator.CollectAllData(ctx, &day)
+		if err != nil {
+			// Keep the days collected so far
+			if saveErr := saveBatch(); saveErr != nil {
+				log.Printf("Failed to save backfilled days: %v", saveErr)
+			}
+			return fmt.Errorf("failed to collect data for %s: %w", date, err)
+		}
+		if name := failedCollector(data, collectors.Daily(aggregator.Collectors())); name != "" {
+			log.Printf("Skipping %s because the %s collector failed", date, name)
+			skipped = append(skipped, date)
+			continue
+		}
+
+		// Keep only the values belonging to this day
+		record := history.Record{Date: date, Data: data.ForDate(date)}
+		if !day.Before(to) {
+			record.Data.CopyLatest(data)
+		}
+		batch = append(batch, record)
+
+		days++
+		log.Printf("Backfilled %s", date)
+
+		if len(batch) >= backfillBatchDays {
+			if err := saveBatch(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := saveBatch(); err != nil {
+		return err
+	}
+
+	log.Printf("Backfilled %d days in %v", days, time.Since(start))
+	if err := writeHistoryOutput(aggregator, store, historyOutputPath); err != nil {
+		return err
+	}
+
+	if len(skipped) > 0 {
+		return fmt.Errorf("skipped %d days with failed collectors, backfill them again: %s", len(skipped), strings.Join(skipped, ", "))
+	}
+	return nil
+}
+
+// collectorNames returns a comma-separated list of collector names for logging
+func collectorNames(list []collectors.Collector) string {
+	names := make([]string, 0, len(list))
+	for _, collector := range list {
+		names = append(names, collector.Name())
+	}
+	return strings.Join(names, ", ")
+}
+
+// parseBackfillRange parses the backfill dates in the reporting timezone,
+// defaulting the end to yesterday
+func parseBackfillRange(fromStr, toStr string, location *time.Location) (time.Time, time.Time, error) {
+	from, err := time.ParseInLocation("2006-01-02", fromStr, location)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid start date '%s': %w", fromStr, err)
+	}
+
+	now := time.Now().In(location)
+	to := time.Date(now.Year(), now.Month(), now.Day()-1, 0, 0, 0, 0, location)
+	if toStr != "" {
+		to, err = time.ParseInLocation("2006-01-02", toStr, location)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid end date '%s': %w", toStr, err)
+		}
+	}
+
+	if to.Before(from) {
+		return time.Time{}, time.Time{}, fmt.Errorf("end date %s is before start date %s", to.Format("2006-01-02"), from.Format("2006-01-02"))
+	}
+
+	return from, to, nil
+}
+
 // Config holds all configuration for the KPI service
 type Config struct {
-	MongoURI       string
-	MongoDB        string
-	NostrRelays    []string
-	OutputPath     string
-	UpdateInterval time.Duration
-	NsecStats      string
+	MongoURI              string
+	MongoDB               string
+	NostrRelays           []string
+	OutputPath            string
+	HistoryPath           string
+	HistoryOutputPath     string
+	RollupOutputPath      string
+	UpdateInterval        time.Duration
+	NsecStats             string
+	LookbackDays          int
+	EnabledCollectors     []string
+	ExtraCollectors       []string
+	DisabledCollectors    []string
+	HTTPAddr              string
+	MaxParallelCollectors int
+	RelayConnectTimeout   time.Duration
+	RelayQueryTimeout     time.Duration
+	NostrAuthorBatchSize  int
+	NostrPageLimit        int
+	NostrKindsFile        string
+	GeoTopN               int
+	CohortGranularity     string
+	CohortPeriods         int
+	CohortCSVPath         string
+	ContactsStatePath     string
+	Granularity           string
+	ReportTimezone        string
 }
 
 // loadConfig loads configuration from .env file or environment variables
@@ -137,12 +526,33 @@ func loadConfig() *Config {
 	// If .env file doesn't exist or is empty, fall back to environment variables
 	if config == nil {
 		config = &Config{
-			MongoURI:       getEnv("MONGO_URI", "mongodb://localhost:27017"),
-			MongoDB:        getEnv("MONGO_DB", "trustroots"),
-			NostrRelays:    strings.Split(getEnv("NOSTR_RELAYS", "wss://relay.trustroots.org,wss://relay.nomadwiki.org"), ","),
-			OutputPath:     getEnv("OUTPUT_PATH", "public/kpi.json"),
-			UpdateInterval: time.Duration(getEnvInt("UPDATE_INTERVAL_MINUTES", 60)) * time.Minute,
-			NsecStats:      getEnv("NSEC_STATS", ""),
+			MongoURI:              getEnv("MONGO_URI", "mongodb://localhost:27017"),
+			MongoDB:               getEnv("MONGO_DB", "trustroots"),
+			NostrRelays:           strings.Split(getEnv("NOSTR_RELAYS", "wss://relay.trustroots.org,wss://relay.nomadwiki.org"), ","),
+			OutputPath:            getEnv("OUTPUT_PATH", "public/kpi.json"),
+			HistoryPath:           getEnv("HISTORY_PATH", "data/history.jsonl"),
+			HistoryOutputPath:     getEnv("HISTORY_OUTPUT_PATH", "public/kpi-history.json"),
+			RollupOutputPath:      getEnv("ROLLUP_OUTPUT_PATH", ""),
+			UpdateInterval:        time.Duration(getEnvInt("UPDATE_INTERVAL_MINUTES", 60)) * time.Minute,
+			NsecStats:             getEnv("NSEC_STATS", ""),
+			LookbackDays:          getEnvInt("LOOKBACK_DAYS", 7),
+			EnabledCollectors:     splitList(getEnv("COLLECTORS", "")),
+			ExtraCollectors:       splitList(getEnv("EXTRA_COLLECTORS", "")),
+			DisabledCollectors:    splitList(getEnv("DISABLED_COLLECTORS", "")),
+			HTTPAddr:              getEnv("HTTP_ADDR", ""),
+			MaxParallelCollectors: getEnvInt("MAX_PARALLEL_COLLECTORS", 4),
+			RelayConnectTimeout:   time.Duration(getEnvInt("RELAY_CONNECT_TIMEOUT_SECONDS", 10)) * time.Second,
+			RelayQueryTimeout:     time.Duration(getEnvInt("RELAY_QUERY_TIMEOUT_SECONDS", 30)) * time.Second,
+			NostrAuthorBatchSize:  getEnvInt("NOSTR_AUTHOR_BATCH_SIZE", 100),
+			NostrPageLimit:        getEnvInt("NOSTR_PAGE_LIMIT", 500),
+			NostrKindsFile:        getEnv("NOSTR_KINDS_FILE", ""),
+			GeoTopN:               getEnvInt("GEO_TOP_N", 10),
+			CohortGranularity:     getEnv("COHORT_GRANULARITY", "month"),
+			CohortPeriods:         getEnvInt("COHORT_PERIODS", 6),
+			CohortCSVPath:         getEnv("COHORT_CSV_PATH", ""),
+			ContactsStatePath:     getEnv("CONTACTS_STATE_PATH", "data/contacts-state.json"),
+			Granularity:           getEnv("GRANULARITY", "day"),
+			ReportTimezone:        getEnv("REPORT_TIMEZONE", "UTC"),
 		}
 	}
 
@@ -151,8 +561,68 @@ func loadConfig() *Config {
 		config.NostrRelays[i] = strings.TrimSpace(relay)
 	}
 
-	// Ensure output path is absolute
+	// Default collection window when not set in the .env file
+	if config.LookbackDays == 0 {
+		config.LookbackDays = 7
+	}
+	if config.Granularity == "" {
+		config.Granularity = "day"
+	}
+	if config.ReportTimezone == "" {
+		config.ReportTimezone = "UTC"
+	}
+
+	// Default cohorts when not set in the .env file
+	if config.CohortGranularity == "" {
+		config.CohortGranularity = "month"
+	}
+	if config.CohortPeriods == 0 {
+		config.CohortPeriods = 6
+	}
+
+	// Default collector parallelism when not set in the .env file
+	if config.MaxParallelCollectors == 0 {
+		config.MaxParallelCollectors = 4
+	}
+
+	// Default relay timeouts when not set in the .env file
+	if config.RelayConnectTimeout == 0 {
+		config.RelayConnectTimeout = 10 * time.Second
+	}
+	if config.RelayQueryTimeout == 0 {
+		config.RelayQueryTimeout = 30 * time.Second
+	}
+
+	// Default relay paging when not set in the .env file
+	if config.NostrAuthorBatchSize == 0 {
+		config.NostrAuthorBatchSize = 100
+	}
+	if config.NostrPageLimit == 0 {
+		config.NostrPageLimit = 500
+	}
+
+	// Default history paths when not set in the .env file
+	if config.HistoryPath == "" {
+		config.HistoryPath = "data/history.jsonl"
+	}
+	if config.HistoryOutputPath == "" {
+		config.HistoryOutputPath = "public/kpi-history.json"
+	}
+	if config.ContactsStatePath == "" {
+		config.ContactsStatePath = "data/contacts-state.json"
+	}
+
+	// Ensure output paths are absolute
 	config.OutputPath = resolveOutputPath(config.OutputPath)
+	config.HistoryPath = resolveOutputPath(config.HistoryPath)
+	config.HistoryOutputPath = resolveOutputPath(config.HistoryOutputPath)
+	if config.RollupOutputPath != "" {
+		config.RollupOutputPath = resolveOutputPath(config.RollupOutputPath)
+	}
+	if config.CohortCSVPath != "" {
+		config.CohortCSVPath = resolveOutputPath(config.CohortCSVPath)
+	}
+	config.ContactsStatePath = resolveOutputPath(config.ContactsStatePath)
 
 	return config
 }
@@ -165,6 +635,17 @@ func getEnv(key, defaultValue string) string {
 	return defaultValue
 }
 
+// splitList splits a comma-separated value into trimmed, non-empty items
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
 // getEnvInt gets an environment variable as integer with a default value
 func getEnvInt(key string, defaultValue int) int {
 	if value := os.Getenv(key); value != "" {
@@ -212,12 +693,70 @@ func loadConfigFromFile(filename string) *Config {
 			config.NostrRelays = strings.Split(value, ",")
 		case "OUTPUT_PATH":
 			config.OutputPath = value
+		case "HISTORY_PATH":
+			config.HistoryPath = value
+		case "HISTORY_OUTPUT_PATH":
+			config.HistoryOutputPath = value
+		case "ROLLUP_OUTPUT_PATH":
+			config.RollupOutputPath = value
 		case "UPDATE_INTERVAL_MINUTES":
 			if intValue, err := strconv.Atoi(value); err == nil {
 				config.UpdateInterval = time.Duration(intValue) * time.Minute
 			}
 		case "NSEC_STATS":
 			config.NsecStats = value
+		case "LOOKBACK_DAYS":
+			if intValue, err := strconv.Atoi(value); err == nil {
+				config.LookbackDays = intValue
+			}
+		case "GRANULARITY":
+			config.Granularity = value
+		case "REPORT_TIMEZONE":
+			config.ReportTimezone = value
+		case "COLLECTORS":
+			config.EnabledCollectors = splitList(value)
+		case "EXTRA_COLLECTORS":
+			config.ExtraCollectors = splitList(value)
+		case "DISABLED_COLLECTORS":
+			config.DisabledCollectors = splitList(value)
+		case "HTTP_ADDR":
+			config.HTTPAddr = value
+		case "MAX_PARALLEL_COLLECTORS":
+			if intValue, err := strconv.Atoi(value); err == nil {
+				config.MaxParallelCollectors = intValue
+			}
+		case "RELAY_CONNECT_TIMEOUT_SECONDS":
+			if intValue, err := strconv.Atoi(value); err == nil {
+				config.RelayConnectTimeout = time.Duration(intValue) * time.Second
+			}
+		case "RELAY_QUERY_TIMEOUT_SECONDS":
+			if intValue, err := strconv.Atoi(value); err == nil {
+				config.RelayQueryTimeout = time.Duration(intValue) * time.Second
+			}
+		case "NOSTR_AUTHOR_BATCH_SIZE":
+			if intValue, err := strconv.Atoi(value); err == nil {
+				config.NostrAuthorBatchSize = intValue
+			}
+		case "NOSTR_PAGE_LIMIT":
+			if intValue, err := strconv.Atoi(value); err == nil {
+				config.NostrPageLimit = intValue
+			}
+		case "NOSTR_KINDS_FILE":
+			config.NostrKindsFile = value
+		case "GEO_TOP_N":
+			if intValue, err := strconv.Atoi(value); err == nil {
+				config.GeoTopN = intValue
+			}
+		case "COHORT_GRANULARITY":
+			config.CohortGranularity = value
+		case "COHORT_PERIODS":
+			if intValue, err := strconv.Atoi(value); err == nil {
+				config.CohortPeriods = intValue
+			}
+		case "COHORT_CSV_PATH":
+			config.CohortCSVPath = value
+		case "CONTACTS_STATE_PATH":
+			config.ContactsStatePath = value
 		}
 	}
 
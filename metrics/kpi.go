@@ -0,0 +1,179 @@
+package metrics
+
+import (
+	"time"
+
+	"kpi.trustroots.org/models"
+)
+
+// ObserveCollection records the duration and outcome of a full collection run
+func ObserveCollection(duration time.Duration, err error) {
+	SetGauge("kpi_collection_duration_seconds", "Duration of the last KPI collection run.", duration.Seconds())
+	AddCounter("kpi_collections_total", "KPI collection runs by result.", 1, "result", result(err))
+	if err == nil {
+		SetGauge("kpi_collection_last_success_timestamp_seconds", "Unix time of the last successful KPI collection run.", float64(time.Now().Unix()))
+	}
+}
+
+// ObserveCollector records the duration and outcome of a single collector
+func ObserveCollector(collector string, duration time.Duration, err error) {
+	SetGauge("kpi_collector_duration_seconds", "Duration of the last run of each collector.", duration.Seconds(), "collector", collector)
+	AddCounter("kpi_collector_runs_total", "Collector runs by result.", 1, "collector", collector, "result", result(err))
+}
+
+// ObserveMongoQuery records the latency of a MongoDB query made by a collector
+func ObserveMongoQuery(collector string, duration time.Duration, err error) {
+	SetGauge("kpi_mongo_query_last_duration_seconds", "Latency of the last MongoDB query of each collector.", duration.Seconds(), "collector", collector)
+	Observe("kpi_mongo_query_duration_seconds", "Latency of MongoDB queries per collector.", duration.Seconds(), "collector", collector)
+	AddCounter("kpi_mongo_queries_total", "MongoDB queries per collector by result.", 1, "collector", collector, "result", result(err))
+}
+
+// ObserveRelay records the outcome of a relay operation (query or publish)
+func ObserveRelay(relay, operation string, err error) {
+	AddCounter("kpi_relay_requests_total", "Nostr relay requests by relay, operation and result.", 1, "relay", relay, "operation", operation, "result", result(err))
+}
+
+// RecordKPIs exposes the values of the last complete day as gauges. Days are
+// counted in the reporting timezone of data.Generated.
+func RecordKPIs(data *models.KPIData) {
+	yesterday := data.Generated.AddDate(0, 0, -1).Format("2006-01-02")
+
+	var messages, positive, negative, unknown, upvotes, downvotes, otherVotes int
+	var replyMs int64
+	for _, m := range data.Trustroots.MessagesPerDay {
+		if m.Date == yesterday {
+			messages = m.Count
+		}
+	}
+	for _, r := range data.Trustroots.ReviewsPerDay {
+		if r.Date == yesterday {
+			positive, negative, unknown = r.Positive, r.Negative, r.Unknown
+		}
+	}
+	for _, v := range data.Trustroots.ThreadVotesPerDay {
+		if v.Date == yesterday {
+			upvotes, downvotes, otherVotes = v.Upvotes, v.Downvotes, v.Other
+		}
+	}
+	for _, t := range data.Trustroots.TimeToFirstReplyPerDay {
+		if t.Date == yesterday && t.AvgMs != nil {
+			replyMs = *t.AvgMs
+		}
+	}
+
+	SetGauge("kpi_trustroots_messages", "Messages sent on the last complete day.", float64(messages))
+	SetGauge("kpi_trustroots_reviews", "Experiences written on the last complete day by recommendation.", float64(positive), "recommend", "yes")
+	SetGauge("kpi_trustroots_reviews", "Experiences written on the last complete day by recommendation.", float64(negative), "recommend", "no")
+	SetGauge("kpi_trustroots_reviews", "Experiences written on the last complete day by recommendation.", float64(unknown), "recommend", "unknown")
+	SetGauge("kpi_trustroots_thread_votes", "Reference thread votes on the last complete day by direction.", float64(upvotes), "vote", "up")
+	SetGauge("kpi_trustroots_thread_votes", "Reference thread votes on the last complete day by direction.", float64(downvotes), "vote", "down")
+	SetGauge("kpi_trustroots_thread_votes", "Reference thread votes on the last complete day by direction.", float64(otherVotes), "vote", "other")
+	SetGauge("kpi_trustroots_reply_time_avg_seconds", "Average time to first reply for conversations started on the last complete day.", float64(replyMs)/1000)
+
+	Reset("kpi_trustroots_reply_time_median_seconds")
+	Reset("kpi_trustroots_reply_rate")
+	for _, r := range data.Trustroots.ReplyDistributionPerDay {
+		if r.Date != yesterday {
+			continue
+		}
+		if r.MedianMs != nil {
+			SetGauge("kpi_trustroots_reply_time_median_seconds", "Median time to first reply for conversations started on the last complete day.", float64(*r.MedianMs)/1000)
+		}
+		if r.ReplyRate != nil {
+			SetGauge("kpi_trustroots_reply_rate", "Share of conversations started on the last complete day that got a reply.", *r.ReplyRate)
+		}
+	}
+
+	Reset("kpi_trustroots_conversation_funnel")
+	for _, c := range data.Trustroots.ConversationFunnelPerDay {
+		if c.Date != yesterday {
+			continue
+		}
+		SetGauge("kpi_trustroots_conversation_funnel", "Conversations started on the last complete day that reached each funnel step.", float64(c.FirstContacts), "step", "first_contact")
+		SetGauge("kpi_trustroots_conversation_funnel", "Conversations started on the last complete day that reached each funnel step.", float64(c.Replied), "step", "replied")
+		SetGauge("kpi_trustroots_conversation_funnel", "Conversations started on the last complete day that reached each funnel step.", float64(c.Met), "step", "met")
+	}
+
+	Reset("kpi_trustroots_signups")
+	for _, s := range data.Trustroots.SignupsPerDay {
+		if s.Date != yesterday {
+			continue
+		}
+		SetGauge("kpi_trustroots_signups", "Users who signed up on the last complete day by onboarding step.", float64(s.Signups), "step", "signed_up")
+		SetGauge("kpi_trustroots_signups", "Users who signed up on the last complete day by onboarding step.", float64(s.Confirmed), "step", "confirmed_email")
+		SetGauge("kpi_trustroots_signups", "Users who signed up on the last complete day by onboarding step.", float64(s.CompleteProfiles), "step", "completed_profile")
+	}
+
+	Reset("kpi_trustroots_active_users")
+	Reset("kpi_trustroots_stickiness")
+	for _, a := range data.Trustroots.ActiveUsersPerDay {
+		if a.Date != yesterday {
+			continue
+		}
+		SetGauge("kpi_trustroots_active_users", "Distinct active users in the day, week or month ending on the last complete day.", float64(a.DAU), "period", "day")
+		SetGauge("kpi_trustroots_active_users", "Distinct active users in the day, week or month ending on the last complete day.", float64(a.WAU), "period", "week")
+		SetGauge("kpi_trustroots_active_users", "Distinct active users in the day, week or month ending on the last complete day.", float64(a.MAU), "period", "month")
+		if a.Stickiness != nil {
+			SetGauge("kpi_trustroots_stickiness", "Daily active users divided by monthly active users on the last complete day.", *a.Stickiness)
+		}
+	}
+
+	SetGauge("kpi_trustroots_active_hosts", "Hosts currently accepting guests.", float64(data.Trustroots.ActiveHosts))
+	Reset("kpi_trustroots_new_offers")
+	for _, o := range data.Trustroots.OffersPerDay {
+		if o.Date != yesterday {
+			continue
+		}
+		SetGauge("kpi_trustroots_new_offers", "Offers created on the last complete day by type and status.", float64(o.NewHostYes), "type", "host", "status", "yes")
+		SetGauge("kpi_trustroots_new_offers", "Offers created on the last complete day by type and status.", float64(o.NewHostMaybe), "type", "host", "status", "maybe")
+		SetGauge("kpi_trustroots_new_offers", "Offers created on the last complete day by type and status.", float64(o.NewHostNo), "type", "host", "status", "no")
+		SetGauge("kpi_trustroots_new_offers", "Offers created on the last complete day by type and status.", float64(o.NewMeet), "type", "meet")
+	}
+
+	Reset("kpi_trustroots_contact_requests")
+	for _, c := range data.Trustroots.ContactsPerDay {
+		if c.Date != yesterday {
+			continue
+		}
+		SetGauge("kpi_trustroots_contact_requests", "Contact requests sent on the last complete day by status.", float64(c.Requests), "status", "requested")
+		SetGauge("kpi_trustroots_contact_requests", "Contact requests sent on the last complete day by status.", float64(c.Confirmed), "status", "confirmed")
+	}
+	Reset("kpi_trustroots_contacts_per_active_user")
+	for _, bucket := range data.Trustroots.ContactsPerActiveUser {
+		SetGauge("kpi_trustroots_contacts_per_active_user", "Users seen in the last 30 days by their number of confirmed contacts.", float64(bucket.Users), "contacts", bucket.Contacts)
+	}
+
+	SetGauge("kpi_nostroots_npub_users", "Users with a valid npub.", float64(data.Nostroots.UsersWithNpubs))
+	SetGauge("kpi_nostroots_active_posters", "Users with npubs who posted within the collection window.", float64(data.Nostroots.ActivePosters))
+
+	SetGauge("kpi_nostroots_duplicate_events", "Extra copies of events returned by more than one relay in the last collection.", float64(data.Nostroots.RelayOverlap.Duplicates))
+	Reset("kpi_nostroots_relay_exclusive_events")
+	for _, relay := range data.Nostroots.RelayOverlap.PerRelay {
+		SetGauge("kpi_nostroots_relay_exclusive_events", "Events only returned by this relay in the last collection.", float64(relay.Exclusive), "relay", relay.URL)
+	}
+
+	Reset("kpi_nostroots_rejected_events")
+	for _, relay := range data.Nostroots.DataQuality.PerRelay {
+		SetGauge("kpi_nostroots_rejected_events", "Relay events that failed verification in the last collection by reason.", float64(relay.InvalidID), "relay", relay.URL, "reason", "invalid_id")
+		SetGauge("kpi_nostroots_rejected_events", "Relay events that failed verification in the last collection by reason.", float64(relay.InvalidSignature), "relay", relay.URL, "reason", "invalid_signature")
+		SetGauge("kpi_nostroots_rejected_events", "Relay events that failed verification in the last collection by reason.", float64(relay.UnknownAuthor), "relay", relay.URL, "reason", "unknown_author")
+	}
+
+	Reset("kpi_nostroots_notes")
+	for _, notes := range data.Nostroots.NotesByKindPerDay {
+		if notes.Date != yesterday {
+			continue
+		}
+		for kind, count := range notes.Kinds {
+			SetGauge("kpi_nostroots_notes", "Nostr events on the last complete day by kind.", float64(count), "kind", kind)
+		}
+	}
+}
+
+// result converts an error into a result label value
+func result(err error) string {
+	if err != nil {
+		return "failure"
+	}
+	return "success"
+}
@@ -0,0 +1,181 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Registry holds metric families and renders them in the Prometheus text format
+type Registry struct {
+	mu       sync.Mutex
+	families map[string]*family
+}
+
+// family is a metric name with its type, help text and labelled series.
+// For summaries series holds the sums and counts the number of observations.
+type family struct {
+	name   string
+	help   string
+	kind   string
+	series map[string]float64
+	counts map[string]float64
+}
+
+// Default is the registry used by the package-level functions
+var Default = NewRegistry()
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{families: make(map[string]*family)}
+}
+
+// SetGauge sets a gauge value. Labels are given as name, value pairs.
+func (r *Registry) SetGauge(name, help string, value float64, labels ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.family(name, help, "gauge").series[labelString(labels)] = value
+}
+
+// AddCounter increases a counter. Labels are given as name, value pairs.
+func (r *Registry) AddCounter(name, help string, delta float64, labels ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.family(name, help, "counter").series[labelString(labels)] += delta
+}
+
+// Observe adds an observation to a summary without quantiles, exposed as its
+// _sum and _count. Labels are given as name, value pairs.
+func (r *Registry) Observe(name, help string, value float64, labels ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	f := r.family(name, help, "summary")
+	key := labelString(labels)
+	f.series[key] += value
+	f.counts[key]++
+}
+
+// Reset removes all series of a metric, so label sets that no longer apply
+// are not reported with stale values
+func (r *Registry) Reset(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if f, exists := r.families[name]; exists {
+		f.series = make(map[string]float64)
+		f.counts = make(map[string]float64)
+	}
+}
+
+// WriteText writes all metrics in the Prometheus text exposition format
+func (r *Registry) WriteText(w io.Writer) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	names := make([]string, 0, len(r.families))
+	for name := range r.families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	buf := bufio.NewWriter(w)
+	for _, name := range names {
+		f := r.families[name]
+		fmt.Fprintf(buf, "# HELP %s %s\n", f.name, f.help)
+		fmt.Fprintf(buf, "# TYPE %s %s\n", f.name, f.kind)
+
+		keys := make([]string, 0, len(f.series))
+		for key := range f.series {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			if f.kind == "summary" {
+				fmt.Fprintf(buf, "%s_sum%s %s\n", f.name, key, strconv.FormatFloat(f.series[key], 'g', -1, 64))
+				fmt.Fprintf(buf, "%s_count%s %s\n", f.name, key, strconv.FormatFloat(f.counts[key], 'g', -1, 64))
+				continue
+			}
+			fmt.Fprintf(buf, "%s%s %s\n", f.name, key, strconv.FormatFloat(f.series[key], 'g', -1, 64))
+		}
+	}
+	return buf.Flush()
+}
+
+// Handler returns an HTTP handler serving the registry
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		if err := r.WriteText(w); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
+
+// family returns the family with the given name, creating it if needed.
+// Callers must hold the lock.
+func (r *Registry) family(name, help, kind string) *family {
+	f, exists := r.families[name]
+	if !exists {
+		f = &family{name: name, help: help, kind: kind, series: make(map[string]float64), counts: make(map[string]float64)}
+		r.families[name] = f
+	}
+	return f
+}
+
+// labelString formats name, value label pairs as {name="value",...}
+func labelString(labels []string) string {
+	if len(labels) < 2 {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteString("{")
+	for i := 0; i+1 < len(labels); i += 2 {
+		if i > 0 {
+			b.WriteString(",")
+		}
+		b.WriteString(labels[i])
+		b.WriteString(`="`)
+		b.WriteString(escapeLabel(labels[i+1]))
+		b.WriteString(`"`)
+	}
+	b.WriteString("}")
+	return b.String()
+}
+
+// escapeLabel escapes a label value for the text format
+func escapeLabel(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `"`, `\"`)
+	return strings.ReplaceAll(value, "\n", `\n`)
+}
+
+// SetGauge sets a gauge in the default registry
+func SetGauge(name, help string, value float64, labels ...string) {
+	Default.SetGauge(name, help, value, labels...)
+}
+
+// AddCounter increases a counter in the default registry
+func AddCounter(name, help string, delta float64, labels ...string) {
+	Default.AddCounter(name, help, delta, labels...)
+}
+
+// Observe adds an observation to a summary in the default registry
+func Observe(name, help string, value float64, labels ...string) {
+	Default.Observe(name, help, value, labels...)
+}
+
+// Reset removes all series of a metric in the default registry
+func Reset(name string) {
+	Default.Reset(name)
+}
+
+// Handler returns an HTTP handler serving the default registry
+func Handler() http.Handler {
+	return Default.Handler()
+}
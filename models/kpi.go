@@ -2,29 +2,194 @@ package models
 
 import (
 	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
 	"time"
 )
 
 // KPIData represents the complete KPI data structure
 type KPIData struct {
-	Generated  time.Time      `json:"generated"`
-	Trustroots TrustrootsData `json:"trustroots"`
-	Nostroots  NostrootsData  `json:"nostroots"`
+	Generated  time.Time                  `json:"generated"`
+	Trustroots TrustrootsData             `json:"trustroots"`
+	Nostroots  NostrootsData              `json:"nostroots"`
+	Status     map[string]CollectorStatus `json:"status,omitempty"`
 }
 
-// TrustrootsData contains all Trustroots-specific metrics
+// CollectorStatus reports the outcome of a collector in the last run
+type CollectorStatus struct {
+	OK          bool       `json:"ok"`
+	Error       string     `json:"error,omitempty"`
+	Stale       bool       `json:"stale,omitempty"` // Values were kept from an earlier run
+	LastSuccess *time.Time `json:"lastSuccess,omitempty"`
+	Sections    []string   `json:"sections,omitempty"` // Output fields written by the collector, such as trustroots.messagesPerDay
+}
+
+// TrustrootsData contains all Trustroots-specific metrics.
+//
+// Fields of this and the Nostroots section are kept in the daily history by
+// their shape: slices of rows with a Date field are daily series, split and
+// merged by day automatically. Fields tagged history:"total" are kept with
+// every stored day, fields tagged history:"latest" only with the most recent
+// one, and all other fields only describe the current run. How the fields of
+// the rows are rolled up into weeks or months is declared by their rollup
+// tags, see RollupFields.
 type TrustrootsData struct {
-	MessagesPerDay         []DailyCount  `json:"messagesPerDay"`
-	ReviewsPerDay          []DailyReview `json:"reviewsPerDay"`
-	ThreadVotesPerDay      []DailyVote   `json:"threadVotesPerDay"`
-	TimeToFirstReplyPerDay []DailyTime   `json:"timeToFirstReplyPerDay"`
+	MessagesPerDay           []DailyCount              `json:"messagesPerDay"`
+	ReviewsPerDay            []DailyReview             `json:"reviewsPerDay"`
+	ThreadVotesPerDay        []DailyVote               `json:"threadVotesPerDay"`
+	TimeToFirstReplyPerDay   []DailyTime               `json:"timeToFirstReplyPerDay"`
+	ReplyDistributionPerDay  []DailyReplyDistribution  `json:"replyDistributionPerDay"`
+	ConversationFunnelPerDay []DailyConversationFunnel `json:"conversationFunnelPerDay"`
+	SignupsPerDay            []DailySignups            `json:"signupsPerDay"`
+	ActiveUsersPerDay        []DailyActiveUsers        `json:"activeUsersPerDay"`
+	ContactsPerDay           []DailyContacts           `json:"contactsPerDay"`
+	ContactsPerActiveUser    []ContactBucket           `json:"contactsPerActiveUser" history:"latest"` // Confirmed contacts of users seen in the last 30 days
+	OffersPerDay             []DailyOffers             `json:"offersPerDay"`
+	ActiveHosts              int                       `json:"activeHosts" history:"latest"`     // Hosts currently accepting guests (yes or maybe)
+	OffersByCountry          []CountryCount            `json:"offersByCountry" history:"latest"` // Active hosts by the country they live in
+	Geo                      *GeoData                  `json:"geo,omitempty"`                    // Only set when the geo collector is enabled
+	Cohorts                  *CohortData               `json:"cohorts,omitempty"`
+}
+
+// CohortData is a retention matrix of users grouped by the week or month they
+// signed up in. Like GeoData it covers the current run only and is not kept
+// in the daily history.
+type CohortData struct {
+	Granularity string      `json:"granularity"`
+	Cohorts     []CohortRow `json:"cohorts"`
+}
+
+// CohortRow is the retention of the users who signed up in a single period.
+// Retained[i] counts the cohort members who sent a message or wrote an
+// experience i periods after signing up, up to the current period.
+type CohortRow struct {
+	Cohort    string    `json:"cohort"`
+	Size      int       `json:"size"`
+	Retained  []int     `json:"retained"`
+	Retention []float64 `json:"retention"` // Retained as a share of Size
+}
+
+// GeoData breaks activity in the collection window down by the country and
+// city users live in. Top tables hold the places with the highest totals, and
+// series are reported for every place in any top table. It covers the current
+// window only and is not kept in the daily history.
+type GeoData struct {
+	TopMessages []CountryCount  `json:"topMessages"`
+	TopReviews  []CountryCount  `json:"topReviews"`
+	TopSignups  []CountryCount  `json:"topSignups"`
+	TopOffers   []CountryCount  `json:"topOffers"`
+	Countries   []CountrySeries `json:"countries"`
+	Cities      CityData        `json:"cities"`
+}
+
+// CityData is the same breakdown by city, the first part of the location users
+// live in, such as "Berlin, Germany"
+type CityData struct {
+	TopMessages []CityCount  `json:"topMessages"`
+	TopReviews  []CityCount  `json:"topReviews"`
+	TopSignups  []CityCount  `json:"topSignups"`
+	TopOffers   []CityCount  `json:"topOffers"`
+	Series      []CitySeries `json:"series"`
+}
+
+// CountrySeries is the daily activity of a single country
+type CountrySeries struct {
+	Country string            `json:"country"`
+	PerDay  []DailyGeoMetrics `json:"perDay"`
+}
+
+// CitySeries is the daily activity of a single city
+type CitySeries struct {
+	City   string            `json:"city"`
+	PerDay []DailyGeoMetrics `json:"perDay"`
+}
+
+// CityCount is a count for a single city
+type CityCount struct {
+	City  string `json:"city"` // City and country, such as "Berlin, Germany"
+	Count int    `json:"count"`
+}
+
+// DailyGeoMetrics counts the activity of users from a country or city on a day
+type DailyGeoMetrics struct {
+	Date     string `json:"date"`
+	Messages int    `json:"messages"` // Messages sent
+	Reviews  int    `json:"reviews"`  // Experiences written
+	Signups  int    `json:"signups"`  // New users
+	Offers   int    `json:"offers"`   // Offers created
 }
 
 // NostrootsData contains all Nostr-specific metrics
 type NostrootsData struct {
-	UsersWithNpubs    int          `json:"usersWithNpubs"`
-	ActivePosters     int          `json:"activePosters"`
-	NotesByKindPerDay []DailyNotes `json:"notesByKindPerDay"`
+	UsersWithNpubs    int           `json:"usersWithNpubs" history:"total"`
+	ActivePosters     int           `json:"activePosters" history:"total"`
+	NotesByKindPerDay []DailyNotes  `json:"notesByKindPerDay"`
+	Kinds             []NostrKind   `json:"kinds,omitempty" history:"latest"`
+	Relays            []RelayResult `json:"relays"`
+	RelayOverlap      RelayOverlap  `json:"relayOverlap"`
+	DataQuality       DataQuality   `json:"dataQuality"`
+}
+
+// NostrKind describes an event kind in the Nostr kind catalogue
+type NostrKind struct {
+	Kind     int    `json:"kind"`
+	Label    string `json:"label"`
+	Category string `json:"category"` // social, community, dm or metadata
+	Query    bool   `json:"query"`    // Fetch events of this kind from relays
+	Report   bool   `json:"report"`   // Count events of this kind in notesByKindPerDay
+}
+
+// DataQuality reports relay events that failed verification and were not counted
+type DataQuality struct {
+	Verified int               `json:"verified"`
+	Rejected int               `json:"rejected"`
+	PerRelay []RelayRejections `json:"perRelay"`
+}
+
+// RelayRejections counts the events of a single relay rejected by reason
+type RelayRejections struct {
+	URL              string `json:"url"`
+	InvalidID        int    `json:"invalidId"`
+	InvalidSignature int    `json:"invalidSignature"`
+	UnknownAuthor    int    `json:"unknownAuthor"`
+}
+
+// Total returns the number of rejected events
+func (r RelayRejections) Total() int {
+	return r.InvalidID + r.InvalidSignature + r.UnknownAuthor
+}
+
+// RelayResult reports the outcome of querying a single relay. TruncatedSeconds
+// counts the seconds skipped while paginating because a page only held events
+// of that second; some of their events may be missing from the counts.
+// PageLimitReached is set when pagination stopped at the page limit before
+// reaching the start of the window, so older events are missing.
+type RelayResult struct {
+	URL              string `json:"url"`
+	Events           int    `json:"events"`
+	LatencyMs        int64  `json:"latencyMs"`
+	TruncatedSeconds int    `json:"truncatedSeconds,omitempty"`
+	PageLimitReached bool   `json:"pageLimitReached,omitempty"`
+	Error            string `json:"error,omitempty"`
+}
+
+// RelayOverlap reports how many events were mirrored across relays
+type RelayOverlap struct {
+	Received   int                 `json:"received"`   // Events received from all relays
+	Unique     int                 `json:"unique"`     // Distinct event IDs
+	Duplicates int                 `json:"duplicates"` // Copies of an event already received from another relay
+	Collapsed  int                 `json:"collapsed"`  // Older versions of replaceable and addressable events
+	Counted    int                 `json:"counted"`    // Events counted after deduplication
+	PerRelay   []RelayOverlapEntry `json:"perRelay"`
+}
+
+// RelayOverlapEntry reports the overlap of a single relay with the others
+type RelayOverlapEntry struct {
+	URL       string `json:"url"`
+	Events    int    `json:"events"`    // Distinct events received from this relay
+	Exclusive int    `json:"exclusive"` // Events no other relay returned
+	Shared    int    `json:"shared"`    // Events also returned by another relay
 }
 
 // DailyCount represents a count for a specific day
@@ -33,24 +198,144 @@ type DailyCount struct {
 	Count int    `json:"count"`
 }
 
-// DailyReview represents review counts for a specific day
+// DailyReview represents experience counts for a specific day by recommendation
+// and interaction. An experience is reciprocated when the other member wrote one
+// back, before or after it; the reciprocation time is the gap between the two.
+// The share is null on days without experiences and the median on days without
+// reciprocated ones.
 type DailyReview struct {
-	Date     string `json:"date"`
-	Positive int    `json:"positive"`
-	Negative int    `json:"negative"`
+	Date                  string   `json:"date"`
+	Positive              int      `json:"positive"` // Recommended
+	Negative              int      `json:"negative"` // Not recommended
+	Unknown               int      `json:"unknown"`  // Recommendation unknown or not given
+	Hosted                int      `json:"hosted"`   // The author hosted the other member
+	Guest                 int      `json:"guest"`    // The author was hosted by the other member
+	Met                   int      `json:"met"`      // The members met
+	Reciprocated          int      `json:"reciprocated"`
+	ReciprocatedShare     *float64 `json:"reciprocatedShare" rollup:"ratio:reciprocated/positive+negative+unknown"`
+	MedianReciprocationMs *int64   `json:"medianReciprocationMs" rollup:"-"`
 }
 
-// DailyVote represents thread vote counts for a specific day
+// DailyVote represents thread vote counts for a specific day. The up/down
+// ratio is null on days without downvotes. Threads and voters are distinct
+// within the day, so daily values cannot be rolled up; the week or month
+// rollup output counts them per period instead.
 type DailyVote struct {
-	Date      string `json:"date"`
-	Upvotes   int    `json:"upvotes"`
-	Downvotes int    `json:"downvotes"`
+	Date        string   `json:"date"`
+	Upvotes     int      `json:"upvotes"`
+	Downvotes   int      `json:"downvotes"`
+	Other       int      `json:"other"`              // Votes with a value other than yes or no
+	Threads     int      `json:"threads" rollup:"-"` // Distinct threads voted on
+	Voters      int      `json:"voters" rollup:"-"`  // Distinct voters
+	UpDownRatio *float64 `json:"upDownRatio" rollup:"ratio:upvotes/downvotes"`
 }
 
-// DailyTime represents average time for a specific day
+// DailyTime represents average time for a specific day. AvgMs is null on days
+// without any replied conversation.
 type DailyTime struct {
 	Date  string `json:"date"`
-	AvgMs int64  `json:"avgMs"`
+	AvgMs *int64 `json:"avgMs" rollup:"-"`
+}
+
+// DailyReplyDistribution describes how quickly the conversations started on a
+// day got their first reply. Percentiles are null on days without replies and
+// the reply rate is null on days without conversations. Buckets do not overlap
+// and add up to the number of conversations.
+type DailyReplyDistribution struct {
+	Date          string   `json:"date"`
+	Conversations int      `json:"conversations"`                                  // Conversations started
+	Replied       int      `json:"replied"`                                        // Conversations with a reply
+	ReplyRate     *float64 `json:"replyRate" rollup:"ratio:replied/conversations"` // Share of conversations with a reply
+	MedianMs      *int64   `json:"medianMs" rollup:"-"`
+	P75Ms         *int64   `json:"p75Ms" rollup:"-"`
+	P90Ms         *int64   `json:"p90Ms" rollup:"-"`
+	Under1h       int      `json:"under1h"` // Replied within an hour
+	Under1d       int      `json:"under1d"` // Replied within a day, after an hour
+	Under1w       int      `json:"under1w"` // Replied within a week, after a day
+	Over1w        int      `json:"over1w"`  // Replied after more than a week
+	Never         int      `json:"never"`   // Not replied to (yet)
+}
+
+// DailyConversationFunnel follows the first contacts made on a day to a reply
+// and to an experience between the two members that says they met or hosted
+// each other. Recent contacts may still progress, so the last days undercount.
+// Shares are of the first contacts and null on days without any; medians are
+// null without conversations reaching the step.
+type DailyConversationFunnel struct {
+	Date                   string   `json:"date"`
+	FirstContacts          int      `json:"firstContacts"`
+	Replied                int      `json:"replied"`
+	ReplyShare             *float64 `json:"replyShare" rollup:"ratio:replied/firstContacts"`
+	Met                    int      `json:"met"`
+	MetShare               *float64 `json:"metShare" rollup:"ratio:met/firstContacts"`
+	MedianReplyMs          *int64   `json:"medianReplyMs" rollup:"-"`          // From first message to first reply
+	MedianReplyToMeetingMs *int64   `json:"medianReplyToMeetingMs" rollup:"-"` // From first reply to the experience
+}
+
+// DailyContacts counts the contact requests sent on a day and how many of them
+// are confirmed by now. Trustroots does not store when a request was confirmed,
+// so the time to confirm is measured from the request to the first collection
+// run that saw it confirmed. It is an upper bound, off by at most twice the
+// update interval, and only covers requests confirmed while the service was
+// running. Confirmations seen after a longer gap between runs are not timed.
+type DailyContacts struct {
+	Date                  string   `json:"date"`
+	Requests              int      `json:"requests"`
+	Confirmed             int      `json:"confirmed"`
+	ConfirmationRate      *float64 `json:"confirmationRate" rollup:"ratio:confirmed/requests"`
+	MedianTimeToConfirmMs *int64   `json:"medianTimeToConfirmMs" rollup:"-"`
+}
+
+// ContactBucket is the number of users with a number of contacts in a range
+type ContactBucket struct {
+	Contacts string `json:"contacts"` // For example "0", "2-5" or "26+"
+	Users    int    `json:"users"`
+}
+
+// DailySignups describes the onboarding of users who signed up on a day. Rates
+// are null on days without signups. NpubsSet counts users with an npub whose
+// profile was last updated on the day, since the time an npub was added is not
+// stored; it undercounts users who changed their profile again later.
+type DailySignups struct {
+	Date                  string   `json:"date"`
+	Signups               int      `json:"signups"`
+	Confirmed             int      `json:"confirmed"` // Confirmed their email address
+	ConfirmedRate         *float64 `json:"confirmedRate" rollup:"ratio:confirmed/signups"`
+	CompleteProfiles      int      `json:"completeProfiles"` // Description, avatar and living location set
+	ProfileCompletionRate *float64 `json:"profileCompletionRate" rollup:"ratio:completeProfiles/signups"`
+	NpubsSet              int      `json:"npubsSet"`
+}
+
+// DailyActiveUsers reports distinct active members on a day and in the 7 and
+// 30 days ending on it. For week and month granularity the values are those of
+// the last day of the period, both in the rollup output and in API rollups.
+// Stickiness is DAU/MAU, null without active users.
+type DailyActiveUsers struct {
+	Date       string   `json:"date"`
+	DAU        int      `json:"dau" rollup:"last"`
+	WAU        int      `json:"wau" rollup:"last"`
+	MAU        int      `json:"mau" rollup:"last"`
+	Stickiness *float64 `json:"stickiness" rollup:"last"`
+}
+
+// DailyOffers counts hosting and meet offers created or updated on a day by
+// type and host status. Updated offers exclude the ones created the same day.
+type DailyOffers struct {
+	Date             string `json:"date"`
+	NewHostYes       int    `json:"newHostYes"`
+	NewHostMaybe     int    `json:"newHostMaybe"`
+	NewHostNo        int    `json:"newHostNo"`
+	NewMeet          int    `json:"newMeet"`
+	UpdatedHostYes   int    `json:"updatedHostYes"`
+	UpdatedHostMaybe int    `json:"updatedHostMaybe"`
+	UpdatedHostNo    int    `json:"updatedHostNo"`
+	UpdatedMeet      int    `json:"updatedMeet"`
+}
+
+// CountryCount is a count for a single country
+type CountryCount struct {
+	Country string `json:"country"`
+	Count   int    `json:"count"`
 }
 
 // DailyNotes represents note counts by kind for a specific day
@@ -69,3 +354,235 @@ func (dn DailyNotes) MarshalJSON() ([]byte, error) {
 	}
 	return json.Marshal(result)
 }
+
+// UnmarshalJSON custom unmarshaling for DailyNotes to restore flattened kinds
+func (dn *DailyNotes) UnmarshalJSON(data []byte) error {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	dn.Kinds = make(map[string]int)
+	for key, value := range raw {
+		if key == "date" {
+			if err := json.Unmarshal(value, &dn.Date); err != nil {
+				return err
+			}
+			continue
+		}
+		if !strings.HasPrefix(key, "kind") {
+			continue
+		}
+		var count int
+		if err := json.Unmarshal(value, &count); err != nil {
+			return err
+		}
+		dn.Kinds[strings.TrimPrefix(key, "kind")] = count
+	}
+	return nil
+}
+
+// Dates returns the sorted list of distinct days present in the daily series
+func (k *KPIData) Dates() []string {
+	seen := make(map[string]bool)
+	k.eachField(k, func(_ string, field reflect.StructField, series, _ reflect.Value) {
+		if !isDailySeries(field) {
+			return
+		}
+		for i := 0; i < series.Len(); i++ {
+			seen[rowDate(series.Index(i))] = true
+		}
+	})
+
+	dates := make([]string, 0, len(seen))
+	for date := range seen {
+		dates = append(dates, date)
+	}
+	sort.Strings(dates)
+	return dates
+}
+
+// ForDate returns a copy of the KPI data restricted to a single day (YYYY-MM-DD).
+// Totals tagged history:"total" are kept as-is, while the larger ones tagged
+// history:"latest" are left out; see CopyLatest.
+func (k *KPIData) ForDate(date string) *KPIData {
+	day := &KPIData{Generated: k.Generated}
+	day.eachField(k, func(_ string, field reflect.StructField, dst, src reflect.Value) {
+		switch {
+		case isDailySeries(field):
+			for i := 0; i < src.Len(); i++ {
+				if row := src.Index(i); rowDate(row) == date {
+					dst.Set(reflect.Append(dst, row))
+				}
+			}
+		case field.Tag.Get("history") == "total":
+			dst.Set(src)
+		}
+	})
+	return day
+}
+
+// CopyLatest copies the totals tagged history:"latest" from src. They are only
+// stored with the most recent day, since merging the history keeps just the
+// last value.
+func (k *KPIData) CopyLatest(src *KPIData) {
+	k.eachField(src, func(_ string, field reflect.StructField, dst, src reflect.Value) {
+		if field.Tag.Get("history") == "latest" {
+			dst.Set(src)
+		}
+	})
+}
+
+// AppendDay adds the daily series of a later day to the data and takes over
+// its totals
+func (k *KPIData) AppendDay(day *KPIData) {
+	k.Generated = day.Generated
+	k.eachField(day, func(_ string, field reflect.StructField, dst, src reflect.Value) {
+		switch field.Tag.Get("history") {
+		case "total":
+			dst.Set(src)
+		case "latest":
+			// Only the most recent day carries these
+			if !src.IsZero() {
+				dst.Set(src)
+			}
+		default:
+			if isDailySeries(field) {
+				dst.Set(reflect.AppendSlice(dst, src))
+			}
+		}
+	})
+}
+
+// Sections returns the names of the non-empty fields of the Trustroots and
+// Nostroots sections, such as trustroots.messagesPerDay
+func (k *KPIData) Sections() []string {
+	var names []string
+	k.eachField(k, func(name string, _ reflect.StructField, value, _ reflect.Value) {
+		if !value.IsZero() {
+			names = append(names, name)
+		}
+	})
+	return names
+}
+
+// CopySections copies the named fields of the Trustroots and Nostroots
+// sections from src, as returned by Sections
+func (k *KPIData) CopySections(src *KPIData, names []string) {
+	copied := make(map[string]bool, len(names))
+	for _, name := range names {
+		copied[name] = true
+	}
+	k.eachField(src, func(name string, _ reflect.StructField, dst, src reflect.Value) {
+		if copied[name] {
+			dst.Set(src)
+		}
+	})
+}
+
+// eachField calls fn for every field of the Trustroots and Nostroots sections,
+// with its qualified JSON name, the field of k as dst and the same field of src
+func (k *KPIData) eachField(src *KPIData, fn func(name string, field reflect.StructField, dst, src reflect.Value)) {
+	sections := []struct {
+		name     string
+		dst, src reflect.Value
+	}{
+		{"trustroots", reflect.ValueOf(&k.Trustroots).Elem(), reflect.ValueOf(&src.Trustroots).Elem()},
+		{"nostroots", reflect.ValueOf(&k.Nostroots).Elem(), reflect.ValueOf(&src.Nostroots).Elem()},
+	}
+	for _, section := range sections {
+		for i := 0; i < section.dst.NumField(); i++ {
+			field := section.dst.Type().Field(i)
+			fn(section.name+"."+jsonName(field), field, section.dst.Field(i), section.src.Field(i))
+		}
+	}
+}
+
+// DailySeriesNames returns the JSON names of the daily series in the
+// Trustroots and Nostroots sections
+func DailySeriesNames() []string {
+	var names []string
+	for _, section := range []reflect.Type{reflect.TypeOf(TrustrootsData{}), reflect.TypeOf(NostrootsData{})} {
+		for i := 0; i < section.NumField(); i++ {
+			if field := section.Field(i); isDailySeries(field) {
+				names = append(names, jsonName(field))
+			}
+		}
+	}
+	return names
+}
+
+// Rollup kinds, declared on the fields of daily series rows with a rollup tag
+const (
+	RollupSum   = ""      // Counts are summed; fields without a tag
+	RollupRatio = "ratio" // Recomputed from summed fields, as in rollup:"ratio:replied/conversations"
+	RollupLast  = "last"  // The value of the last day of the period
+	RollupNone  = "-"     // Medians, percentiles, averages and distinct counts cannot be rolled up
+)
+
+// Rollup describes how a field of daily series rows is rolled up into weeks or
+// months. A ratio is the sum of its numerator fields divided by the sum of its
+// denominator fields, by JSON name.
+type Rollup struct {
+	Kind        string
+	Numerator   []string
+	Denominator []string
+}
+
+// RollupFields returns how the fields of the rows of a daily series are rolled
+// up, by JSON name, as declared by their rollup tags. Fields that are not
+// returned, such as the kinds of notesByKindPerDay, are summed.
+func RollupFields(metric string) map[string]Rollup {
+	for _, section := range []reflect.Type{reflect.TypeOf(TrustrootsData{}), reflect.TypeOf(NostrootsData{})} {
+		for i := 0; i < section.NumField(); i++ {
+			field := section.Field(i)
+			if !isDailySeries(field) || jsonName(field) != metric {
+				continue
+			}
+
+			rows := field.Type.Elem()
+			fields := make(map[string]Rollup)
+			for j := 0; j < rows.NumField(); j++ {
+				name := jsonName(rows.Field(j))
+				if name == "" || name == "-" || name == "date" {
+					continue
+				}
+				fields[name] = parseRollup(rows.Field(j).Tag.Get("rollup"))
+			}
+			return fields
+		}
+	}
+	return nil
+}
+
+// parseRollup parses a rollup tag such as "ratio:met/firstContacts"
+func parseRollup(tag string) Rollup {
+	kind, fraction, _ := strings.Cut(tag, ":")
+	rollup := Rollup{Kind: kind}
+	if kind == RollupRatio {
+		numerator, denominator, _ := strings.Cut(fraction, "/")
+		rollup.Numerator = strings.Split(numerator, "+")
+		rollup.Denominator = strings.Split(denominator, "+")
+	}
+	return rollup
+}
+
+// isDailySeries reports whether a section field is a daily series, a slice of
+// rows with a Date field
+func isDailySeries(field reflect.StructField) bool {
+	if field.Type.Kind() != reflect.Slice || field.Type.Elem().Kind() != reflect.Struct {
+		return false
+	}
+	date, exists := field.Type.Elem().FieldByName("Date")
+	return exists && date.Type.Kind() == reflect.String
+}
+
+// jsonName returns the name a field is encoded as
+func jsonName(field reflect.StructField) string {
+	return strings.Split(field.Tag.Get("json"), ",")[0]
+}
+
+// rowDate returns the Date field of a daily series row
+func rowDate(row reflect.Value) string {
+	return row.FieldByName("Date").String()
+}
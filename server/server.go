@@ -0,0 +1,362 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"kpi.trustroots.org/collectors"
+	"kpi.trustroots.org/history"
+	"kpi.trustroots.org/metrics"
+	"kpi.trustroots.org/models"
+)
+
+// Server serves the static dashboard and a JSON API backed by the latest KPI data
+type Server struct {
+	staticDir string
+	store     *history.Store
+
+	mu       sync.RWMutex
+	body     []byte
+	etag     string
+	modified time.Time
+	series   map[string][]map[string]interface{} // Daily rows by metric, history merged with the latest data
+}
+
+// New creates a new server. The history store is optional and, when set,
+// extends metric ranges beyond the latest collection window.
+func New(staticDir string, store *history.Store) *Server {
+	return &Server{
+		staticDir: staticDir,
+		store:     store,
+	}
+}
+
+// Update replaces the KPI data served by the API. The history store is only
+// read here, so Update should be called after new days were saved.
+func (s *Server) Update(data *models.KPIData) error {
+	body, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal JSON: %w", err)
+	}
+
+	series, err := s.mergedSeries(data)
+	if err != nil {
+		return fmt.Errorf("failed to build metric series: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.series = series
+	s.body = body
+	s.etag = etagFor(body)
+	s.modified = time.Now().UTC().Truncate(time.Second)
+
+	return nil
+}
+
+// Handler returns the HTTP handler for the API, Prometheus metrics and the static dashboard
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/kpi", s.handleKPI)
+	mux.HandleFunc("GET /api/kpi/{metric}", s.handleMetric)
+	mux.Handle("GET /metrics", metrics.Handler())
+	mux.Handle("GET /", http.FileServer(http.Dir(s.staticDir)))
+	return mux
+}
+
+// handleKPI serves the latest KPI data
+func (s *Server) handleKPI(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	body, etag, modified := s.body, s.etag, s.modified
+	s.mu.RUnlock()
+
+	if body == nil {
+		http.Error(w, "KPI data not collected yet", http.StatusServiceUnavailable)
+		return
+	}
+
+	writeJSON(w, r, body, etag, modified)
+}
+
+// handleMetric serves a single metric series, optionally filtered by date
+// range (from, to as YYYY-MM-DD) and rolled up by granularity
+func (s *Server) handleMetric(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	series, modified := s.series, s.modified
+	s.mu.RUnlock()
+
+	if series == nil {
+		http.Error(w, "KPI data not collected yet", http.StatusServiceUnavailable)
+		return
+	}
+
+	query := r.URL.Query()
+	from, to := query.Get("from"), query.Get("to")
+	for _, value := range []string{from, to} {
+		if value == "" {
+			continue
+		}
+		if _, err := time.Parse("2006-01-02", value); err != nil {
+			http.Error(w, fmt.Sprintf("invalid date '%s', use YYYY-MM-DD", value), http.StatusBadRequest)
+			return
+		}
+	}
+
+	granularity := collectors.GranularityDay
+	if value := query.Get("granularity"); value != "" {
+		parsed, err := collectors.ParseGranularity(value)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		granularity = parsed
+	}
+
+	metric := r.PathValue("metric")
+	rows, exists := series[metric]
+	if !exists {
+		http.Error(w, fmt.Sprintf("unknown metric '%s' (only daily series are served)", metric), http.StatusNotFound)
+		return
+	}
+
+	rows = filterRows(rows, from, to)
+	if granularity != collectors.GranularityDay {
+		rows = rollupRows(rows, granularity, models.RollupFields(metric))
+	}
+
+	body, err := json.MarshalIndent(map[string]interface{}{
+		"metric":      metric,
+		"granularity": granularity,
+		"from":        from,
+		"to":          to,
+		"data":        rows,
+	}, "", "  ")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, r, body, etagFor(body), modified)
+}
+
+// mergedSeries returns the daily rows of every series in the latest data,
+// extended with the history store. Latest values win for the same day.
+func (s *Server) mergedSeries(latest *models.KPIData) (map[string][]map[string]interface{}, error) {
+	latestSeries, err := dailySeries(latest)
+	if err != nil {
+		return nil, err
+	}
+
+	historySeries := make(map[string][]map[string]interface{})
+	if s.store != nil {
+		merged, err := s.store.Merged()
+		if err != nil {
+			return nil, err
+		}
+		if historySeries, err = dailySeries(merged); err != nil {
+			return nil, err
+		}
+	}
+
+	series := make(map[string][]map[string]interface{}, len(latestSeries))
+	for metric, latestRows := range latestSeries {
+		byDate := make(map[string]map[string]interface{})
+		for _, row := range historySeries[metric] {
+			byDate[rowDate(row)] = row
+		}
+		for _, row := range latestRows {
+			byDate[rowDate(row)] = row
+		}
+
+		rows := make([]map[string]interface{}, 0, len(byDate))
+		for _, row := range byDate {
+			rows = append(rows, row)
+		}
+		sort.Slice(rows, func(i, j int) bool {
+			return rowDate(rows[i]) < rowDate(rows[j])
+		})
+		series[metric] = rows
+	}
+
+	return series, nil
+}
+
+// dailySeries returns the rows of the daily series in the Trustroots and
+// Nostroots sections by their JSON name. Totals and tables without a date,
+// such as offersByCountry, are not included.
+func dailySeries(data *models.KPIData) (map[string][]map[string]interface{}, error) {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var sections struct {
+		Trustroots map[string]json.RawMessage `json:"trustroots"`
+		Nostroots  map[string]json.RawMessage `json:"nostroots"`
+	}
+	if err := json.Unmarshal(encoded, &sections); err != nil {
+		return nil, err
+	}
+
+	series := make(map[string][]map[string]interface{})
+	for _, metric := range models.DailySeriesNames() {
+		for _, section := range []map[string]json.RawMessage{sections.Trustroots, sections.Nostroots} {
+			value, exists := section[metric]
+			if !exists {
+				continue
+			}
+			var rows []map[string]interface{}
+			if err := json.Unmarshal(value, &rows); err != nil {
+				return nil, fmt.Errorf("failed to decode %s: %w", metric, err)
+			}
+			if rows == nil {
+				rows = []map[string]interface{}{}
+			}
+			series[metric] = rows
+		}
+	}
+
+	return series, nil
+}
+
+// filterRows keeps the rows whose date is within [from, to]
+func filterRows(rows []map[string]interface{}, from, to string) []map[string]interface{} {
+	filtered := make([]map[string]interface{}, 0, len(rows))
+	for _, row := range rows {
+		date := rowDate(row)
+		if from != "" && date < from {
+			continue
+		}
+		if to != "" && date > to {
+			continue
+		}
+		filtered = append(filtered, row)
+	}
+	return filtered
+}
+
+// rollupRows groups daily rows into weeks or months as declared by the rollup
+// tags of the series, see models.RollupFields. Counts are summed and ratios
+// recomputed from the summed counts, while medians, percentiles, averages and
+// distinct counts cannot be rolled up and are null.
+func rollupRows(rows []map[string]interface{}, granularity collectors.Granularity, fields map[string]models.Rollup) []map[string]interface{} {
+	window := collectors.Window{Granularity: granularity}
+
+	type period struct {
+		sums map[string]float64
+		last map[string]interface{}
+	}
+	periods := make(map[string]*period)
+	var order []string
+
+	// Rows are sorted by date, so the last row of a period is its last day
+	for _, row := range rows {
+		day, err := time.Parse("2006-01-02", rowDate(row))
+		if err != nil {
+			continue
+		}
+		key := window.Key(day)
+
+		p, exists := periods[key]
+		if !exists {
+			p = &period{sums: make(map[string]float64), last: make(map[string]interface{})}
+			periods[key] = p
+			order = append(order, key)
+		}
+
+		for field, value := range row {
+			switch fields[field].Kind {
+			case models.RollupSum:
+				if number, ok := value.(float64); ok {
+					p.sums[field] += number
+				}
+			case models.RollupLast:
+				p.last[field] = value
+			}
+		}
+	}
+
+	result := make([]map[string]interface{}, 0, len(order))
+	for _, key := range order {
+		p := periods[key]
+		row := map[string]interface{}{"date": key}
+		for field, sum := range p.sums {
+			row[field] = sum
+		}
+		for field, value := range p.last {
+			row[field] = value
+		}
+		for field, rollup := range fields {
+			switch rollup.Kind {
+			case models.RollupRatio:
+				row[field] = sumRatio(p.sums, rollup.Numerator, rollup.Denominator)
+			case models.RollupNone:
+				row[field] = nil
+			}
+		}
+		result = append(result, row)
+	}
+
+	return result
+}
+
+// sumRatio divides the sum of the numerator fields by the sum of the
+// denominator fields, or returns nil if the denominator is zero
+func sumRatio(sums map[string]float64, numerator, denominator []string) interface{} {
+	var part, total float64
+	for _, field := range numerator {
+		part += sums[field]
+	}
+	for _, field := range denominator {
+		total += sums[field]
+	}
+	if total == 0 {
+		return nil
+	}
+	return part / total
+}
+
+// rowDate returns the date of a series row
+func rowDate(row map[string]interface{}) string {
+	date, _ := row["date"].(string)
+	return date
+}
+
+// writeJSON writes a JSON body with caching headers, answering conditional
+// requests with 304 Not Modified
+func writeJSON(w http.ResponseWriter, r *http.Request, body []byte, etag string, modified time.Time) {
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Last-Modified", modified.Format(http.TimeFormat))
+	w.Header().Set("Cache-Control", "no-cache")
+
+	if match := r.Header.Get("If-None-Match"); match != "" {
+		if match == etag || match == "*" {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	} else if since := r.Header.Get("If-Modified-Since"); since != "" {
+		if t, err := http.ParseTime(since); err == nil && !modified.After(t) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if _, err := w.Write(body); err != nil {
+		log.Printf("Failed to write response: %v", err)
+	}
+}
+
+// etagFor returns a strong ETag for a response body
+func etagFor(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}